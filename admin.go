@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"monopoly/errcode"
+	"monopoly/game"
+)
+
+/* ===== Admin API ===== */
+
+// withAdmin requires "Authorization: Bearer <admin token>". Without a
+// configured token the whole API answers NOT_FOUND.
+func withAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AdminToken == "" {
+			writeError(w, errorMsg("", errcode.New(errcode.NotFound, "admin API is disabled")))
+			return
+		}
+		if !isAdmin(r) {
+			writeError(w, errorMsg("", errcode.New(errcode.Unauthorized, "admin token required")))
+			return
+		}
+		h(w, r)
+	}
+}
+
+// isAdmin reports whether r carries the admin token.
+func isAdmin(r *http.Request) bool {
+	return cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(cfg.AdminToken)) == 1
+}
+
+type roomSummary struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	Public      bool       `json:"public"`
+	Phase       game.Phase `json:"phase"`
+	Turn        string     `json:"turn,omitempty"`
+	Players     int        `json:"players"`
+	Connections int        `json:"connections"`
+	Version     uint64     `json:"version"`
+}
+
+type connInfo struct {
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+}
+
+// adminRoomsHTTP lists every room, public or private, including rooms
+// nobody has joined yet.
+func adminRoomsHTTP(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		summary roomSummary
+		g       *game.Room
+	}
+	mu.Lock()
+	list := make(map[string]*entry, len(lobby))
+	for id, m := range lobby {
+		list[id] = &entry{summary: roomSummary{ID: id, Name: m.Name, Public: m.Public, Phase: game.PhaseWaiting}}
+	}
+	for id, g := range games {
+		if list[id] == nil {
+			list[id] = &entry{summary: roomSummary{ID: id}}
+		}
+		list[id].g = g
+	}
+	for id, e := range list {
+		e.summary.Connections = len(rooms[id])
+	}
+	mu.Unlock()
+
+	out := make([]roomSummary, 0, len(list))
+	for _, e := range list {
+		s := e.summary
+		if e.g != nil {
+			s.Phase = e.g.Phase()
+			s.Turn = e.g.Turn()
+			s.Players = len(e.g.Players())
+			s.Version = e.g.Version()
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	writeJSON(w, out)
+}
+
+// adminRoomHTTP returns a room's full state and its open connections.
+func adminRoomHTTP(w http.ResponseWriter, r *http.Request) {
+	room := r.PathValue("room")
+	mu.Lock()
+	g := games[room]
+	var list []connInfo
+	for c := range rooms[room] {
+		list = append(list, connInfo{PlayerID: c.ID, Name: c.Name, IP: c.IP})
+	}
+	mu.Unlock()
+	if g == nil {
+		writeError(w, errorMsg("", errNoRoom))
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].PlayerID < list[j].PlayerID })
+	writeJSON(w, map[string]any{
+		"phase":       g.Phase(),
+		"state":       g.Snapshot(),
+		"connections": list,
+	})
+}
+
+func adminKickHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlayerID string `json:"playerId"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlayerID == "" {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "playerId is required")))
+		return
+	}
+	adminDo(w, r, "kick", func(room string) error { return kick(room, req.PlayerID, req.Reason) }, logPlayer, req.PlayerID)
+}
+
+// adminMuteHTTP mutes or unmutes a player's chat, as the host can.
+func adminMuteHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlayerID string `json:"playerId"`
+		Muted    *bool  `json:"muted"` // default true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlayerID == "" {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "playerId is required")))
+		return
+	}
+	muted := req.Muted == nil || *req.Muted
+	adminDo(w, r, "mute", func(room string) error { return setMuted(room, req.PlayerID, muted) }, logPlayer, req.PlayerID, "muted", muted)
+}
+
+func adminPassHTTP(w http.ResponseWriter, r *http.Request) {
+	adminDo(w, r, "pass", pass)
+}
+
+func adminPauseHTTP(paused bool) http.HandlerFunc {
+	cmd := "resume"
+	if paused {
+		cmd = "pause"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminDo(w, r, cmd, func(room string) error { return setPaused(room, paused) })
+	}
+}
+
+func adminCloseHTTP(w http.ResponseWriter, r *http.Request) {
+	adminDo(w, r, "close", closeRoom)
+}
+
+// adminBroadcastHTTP sends an admin message to one room, or to every room
+// when none is given.
+func adminBroadcastHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Room string `json:"room"`
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == "" {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "text is required")))
+		return
+	}
+	if err := announce(req.Room, req.Text); err != nil {
+		writeError(w, errorMsg("", err))
+		return
+	}
+	slog.Info("admin command", logRoom, req.Room, logCmd, "broadcast", "text", req.Text)
+	writeJSON(w, map[string]any{"ok": true})
+}
+
+// adminDo runs a room command for the {room} in the path and logs it.
+func adminDo(w http.ResponseWriter, r *http.Request, cmd string, fn func(room string) error, attrs ...any) {
+	room := r.PathValue("room")
+	if err := fn(room); err != nil {
+		writeError(w, errorMsg("", err))
+		return
+	}
+	roomLog(room).Info("admin command", append([]any{logCmd, cmd}, attrs...)...)
+	writeJSON(w, map[string]any{"ok": true})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"monopoly/errcode"
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+/* ===== Chat ===== */
+
+// chatHistoryLen is how many chat lines a room keeps for sync.
+const chatHistoryLen = 100
+
+// chatRoom is a room's recent chat and muted players, guarded by mu. It
+// lives as long as the room's game.
+type chatRoom struct {
+	history []protocol.ChatMessage
+	muted   map[string]bool
+}
+
+// chats holds each room's chat, guarded by mu.
+var chats = make(map[string]*chatRoom)
+
+// chatFilter vets every chat line before it is delivered. It may rewrite
+// the text, or return an error to refuse it. The default masks the words
+// configured with -chat-blocked-words; swap it out for a smarter filter.
+var chatFilter = blockedWords("")
+
+// blockedWords returns a filter that masks each of the comma-separated
+// words, whole-word and case-insensitively.
+func blockedWords(list string) func(room, playerID, text string) (string, error) {
+	var words []string
+	for _, w := range strings.Split(list, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, regexp.QuoteMeta(w))
+		}
+	}
+	if len(words) == 0 {
+		return func(_, _, text string) (string, error) { return text, nil }
+	}
+	re := regexp.MustCompile(`(?i)\b(` + strings.Join(words, "|") + `)\b`)
+	return func(_, _, text string) (string, error) {
+		return re.ReplaceAllStringFunc(text, func(m string) string {
+			return strings.Repeat("*", len([]rune(m)))
+		}), nil
+	}
+}
+
+// chatLocked returns room's chat, creating it. Callers hold mu.
+func chatLocked(room string) *chatRoom {
+	cr := chats[room]
+	if cr == nil {
+		cr = &chatRoom{muted: make(map[string]bool)}
+		chats[room] = cr
+	}
+	return cr
+}
+
+// chat delivers a line from c to the room, or to in.To as a whisper. Chat
+// does not go through broadcast: it is not part of the game's event log.
+func chat(c *Client, in *protocol.Chat) error {
+	g := getGame(c.Room)
+	if g == nil {
+		return game.ErrNotSeated
+	}
+	if in.To == c.ID {
+		return errcode.New(errcode.BadMessage, "Cannot whisper to yourself.")
+	}
+	var to game.Player
+	if in.To != "" {
+		p, ok := g.Player(in.To)
+		if !ok {
+			return errcode.New(errcode.NotSeated, "That player is not in the room.")
+		}
+		to = p
+	}
+	text, err := chatFilter(c.Room, c.ID, strings.TrimSpace(in.Text))
+	if err != nil {
+		return errcode.New(errcode.BadMessage, err.Error())
+	}
+
+	if isMuted(c.Room, c.ID) {
+		return errcode.New(errcode.Muted, "The host has muted you.")
+	}
+
+	msg := &protocol.ChatMessage{From: game.Player{ID: c.ID, Name: c.Name}, Text: text, To: to.ID, Time: time.Now().UnixMilli()}
+	if to.ID == "" {
+		fanout(c.Room, msg, nil)
+		clientLog(c).Debug("chat", "len", len(text))
+	} else {
+		fanout(c.Room, msg, func(cl *Client) bool { return cl.ID == c.ID || cl.ID == to.ID })
+		clientLog(c).Debug("whisper", "to", to.ID, "len", len(text))
+	}
+
+	// Kept after fanout, which stamps the envelope history replays.
+	mu.Lock()
+	if games[c.Room] == g { // not torn down meanwhile
+		cr := chatLocked(c.Room)
+		cr.history = append(cr.history, *msg)
+		if len(cr.history) > chatHistoryLen {
+			cr.history = slices.Delete(cr.history, 0, len(cr.history)-chatHistoryLen)
+		}
+	}
+	mu.Unlock()
+	return nil
+}
+
+// mute lets the host silence or unmute another player.
+func mute(c *Client, in *protocol.Mute) error {
+	g := getGame(c.Room)
+	if g == nil {
+		return game.ErrNotSeated
+	}
+	if g.Host() != c.ID {
+		return errcode.New(errcode.NotHost, "Only the host can mute players.")
+	}
+	if in.PlayerID == c.ID {
+		return errcode.New(errcode.BadMessage, "Cannot mute yourself.")
+	}
+	return setMuted(c.Room, in.PlayerID, in.Muted)
+}
+
+// setMuted mutes or unmutes playerID in room and tells the room.
+func setMuted(room, playerID string, muted bool) error {
+	g := getGame(room)
+	if g == nil {
+		return errNoRoom
+	}
+	p, ok := g.Player(playerID)
+	if !ok {
+		return game.ErrNotSeated
+	}
+	mu.Lock()
+	cr := chatLocked(room)
+	changed := cr.muted[playerID] != muted
+	if muted {
+		cr.muted[playerID] = true
+	} else {
+		delete(cr.muted, playerID)
+	}
+	mu.Unlock()
+	if !changed {
+		return nil
+	}
+	verb := "muted"
+	if !muted {
+		verb = "unmuted"
+	}
+	broadcast(room, &protocol.Event{Text: fmt.Sprintf("%s was %s.", p.Name, verb)})
+	fanout(room, &protocol.Muted{PlayerID: playerID, Muted: muted}, nil)
+	roomLog(room).Info("chat "+verb, logPlayer, playerID)
+	return nil
+}
+
+func isMuted(room, playerID string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	cr := chats[room]
+	return cr != nil && cr.muted[playerID]
+}
+
+// chatHistory is the recent chat c may see: everything public plus the
+// whispers c sent or received.
+func chatHistory(c *Client) *protocol.ChatHistory {
+	mu.Lock()
+	defer mu.Unlock()
+	out := &protocol.ChatHistory{Messages: []protocol.ChatMessage{}}
+	cr := chats[c.Room]
+	if cr == nil {
+		return out
+	}
+	for _, m := range cr.history {
+		if m.To == "" || m.To == c.ID || m.From.ID == c.ID {
+			out.Messages = append(out.Messages, m)
+		}
+	}
+	for id := range cr.muted {
+		out.Muted = append(out.Muted, id)
+	}
+	slices.Sort(out.Muted)
+	return out
+}
+
+/* ===== Reactions ===== */
+
+// react shows an emote to the whole room. Muted players can't react.
+func react(c *Client, in *protocol.React) error {
+	g := getGame(c.Room)
+	if g == nil {
+		return game.ErrNotSeated
+	}
+	if in.To != "" {
+		if _, ok := g.Player(in.To); !ok {
+			return errcode.New(errcode.NotSeated, "That player is not in the room.")
+		}
+	}
+	if isMuted(c.Room, c.ID) {
+		return errcode.New(errcode.Muted, "The host has muted you.")
+	}
+	fanout(c.Room, &protocol.Reaction{From: game.Player{ID: c.ID, Name: c.Name}, Emote: in.Emote, To: in.To, Time: time.Now().UnixMilli()}, nil)
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"monopoly/errcode"
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+// seatTestClients opens room with a game and seats a connectionless client
+// per player.
+func seatTestClients(t *testing.T, room string, players ...game.Player) []*Client {
+	t.Helper()
+	g := game.NewRoom(room, nil)
+	var out []*Client
+	mu.Lock()
+	games[room] = g
+	rooms[room] = make(map[*Client]struct{})
+	for _, p := range players {
+		c := newClient(nil, "127.0.0.1")
+		c.ID, c.Name, c.Room = p.ID, p.Name, room
+		rooms[room][c] = struct{}{}
+		out = append(out, c)
+	}
+	mu.Unlock()
+	for _, p := range players {
+		g.Join(p)
+	}
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(games, room)
+		delete(rooms, room)
+		delete(chats, room)
+		mu.Unlock()
+	})
+	return out
+}
+
+func TestChatHistory(t *testing.T) {
+	cs := seatTestClients(t, "CHAT01",
+		game.Player{ID: "a", Name: "Alice"}, game.Player{ID: "b", Name: "Bob"}, game.Player{ID: "c", Name: "Carol"})
+	alice, bob, carol := cs[0], cs[1], cs[2]
+
+	if err := chat(alice, &protocol.Chat{Text: "  hello  "}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chat(bob, &protocol.Chat{Text: "psst", To: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	texts := func(c *Client) []string {
+		var out []string
+		for _, m := range chatHistory(c).Messages {
+			out = append(out, m.Text)
+		}
+		return out
+	}
+	for _, tt := range []struct {
+		c    *Client
+		want []string
+	}{
+		{alice, []string{"hello", "psst"}},
+		{bob, []string{"hello", "psst"}},
+		{carol, []string{"hello"}}, // not party to the whisper
+	} {
+		if got := texts(tt.c); !slices.Equal(got, tt.want) {
+			t.Errorf("%s sees %q, want %q", tt.c.Name, got, tt.want)
+		}
+	}
+	if h := chatHistory(carol).Messages; len(h) == 0 || h[0].Type != "chat" || h[0].From.ID != "a" {
+		t.Errorf("history = %+v, want a stamped chat from a first", h)
+	}
+}
+
+func TestChatHistoryCapped(t *testing.T) {
+	cs := seatTestClients(t, "CHAT02", game.Player{ID: "a", Name: "Alice"})
+	for range chatHistoryLen + 5 {
+		if err := chat(cs[0], &protocol.Chat{Text: "spam"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(chatHistory(cs[0]).Messages); n != chatHistoryLen {
+		t.Errorf("history holds %d lines, want %d", n, chatHistoryLen)
+	}
+}
+
+func TestChatMuted(t *testing.T) {
+	cs := seatTestClients(t, "CHAT03", game.Player{ID: "a", Name: "Alice"}, game.Player{ID: "b", Name: "Bob"})
+	alice, bob := cs[0], cs[1]
+	if err := mute(bob, &protocol.Mute{PlayerID: "a", Muted: true}); err == nil {
+		t.Error("Bob muted someone without being host")
+	}
+	if err := mute(alice, &protocol.Mute{PlayerID: "b", Muted: true}); err != nil {
+		t.Fatalf("host mute: %v", err)
+	}
+	if err := chat(bob, &protocol.Chat{Text: "hi"}); err == nil {
+		t.Error("muted player chatted")
+	}
+	if h := chatHistory(alice); len(h.Messages) != 0 || len(h.Muted) != 1 || h.Muted[0] != "b" {
+		t.Errorf("history = %+v, want no lines and b muted", h)
+	}
+}
+
+func TestReact(t *testing.T) {
+	room := newLobbyRoom(t, "")
+	alice, bob := newTestConn(t), newTestConn(t)
+	seat(t, alice, room, "a", "")
+	seat(t, bob, room, "b", "")
+	received(alice)
+	received(bob)
+
+	if err := react(bob, &protocol.React{Emote: "clap", To: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	var r protocol.Reaction
+	if !receivedType(alice, "reaction", &r) || r.From.ID != "b" || r.To != "a" || r.Emote != "clap" {
+		t.Errorf("Alice got %+v, want Bob's clap at her", r)
+	}
+	for _, e := range getEventLog(room).since(0) {
+		if e.Type == "reaction" {
+			t.Error("a reaction was kept in the event log")
+		}
+	}
+
+	if err := react(bob, &protocol.React{Emote: "clap", To: "nobody"}); errCode(err) != errcode.NotSeated {
+		t.Errorf("reaction at an absent player: %v", err)
+	}
+	if err := mute(alice, &protocol.Mute{PlayerID: "b", Muted: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := react(bob, &protocol.React{Emote: "clap"}); errCode(err) != errcode.Muted {
+		t.Errorf("muted player reacted: %v", err)
+	}
+}
+
+func TestReactCatalog(t *testing.T) {
+	for name := range protocol.Emotes {
+		if _, err := protocol.Decode([]byte(`{"type":"react","emote":"` + name + `"}`)); err != nil {
+			t.Errorf("emote %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Clap", "👏", "dance"} {
+		if _, err := protocol.Decode([]byte(`{"type":"react","emote":"` + name + `"}`)); err == nil {
+			t.Errorf("emote %q accepted", name)
+		}
+	}
+}
+
+func TestReactThrottled(t *testing.T) {
+	c := newClient(nil, "192.0.2.2")
+	c.ID, c.Room = "react-"+newJoinCode(), "ROOM01" // a fresh bucket
+	var allowed int
+	for range 10 {
+		if ok, _ := allowInbound(c, &protocol.React{Emote: "clap"}); ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("%d of 10 quick reactions allowed, want the burst of 3", allowed)
+	}
+	if ok, _ := allowInbound(c, &protocol.Chat{Text: "hi"}); !ok {
+		t.Error("reactions used up the chat allowance")
+	}
+}
@@ -0,0 +1,24 @@
+// Command schemagen writes the protocol's JSON Schema. Run it through
+// `go generate ./protocol`.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"monopoly/protocol"
+)
+
+func main() {
+	out := flag.String("o", "schema.json", "output file")
+	flag.Parse()
+
+	b, err := protocol.Schema()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, append(b, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
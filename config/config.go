@@ -0,0 +1,257 @@
+// Package config loads the server configuration. Values are layered, each
+// overriding the one before: built-in defaults, a JSON file (-config),
+// MONOPOLY_* environment variables, then command-line flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Rules are the defaults new rooms are created with.
+type Rules struct {
+	MaxPlayers int  `json:"maxPlayers"`
+	FairDice   bool `json:"fairDice"` // commit-reveal dice (see package dice)
+}
+
+type Config struct {
+	Addr         string `json:"addr"`
+	TLSCert      string `json:"tlsCert,omitempty"` // serve HTTPS when both are set
+	TLSKey       string `json:"tlsKey,omitempty"`
+	RedirectAddr string `json:"redirectAddr,omitempty"` // with TLS, plain HTTP listener that redirects to HTTPS
+	StaticDir    string `json:"staticDir,omitempty"`    // serve the frontend from disk instead of the binary
+	StatePath    string `json:"statePath"`              // room checkpoint file
+
+	AllowedOrigins string `json:"allowedOrigins"` // comma-separated, see originPolicy
+	SessionSecret  string `json:"sessionSecret,omitempty"`
+	AdminToken     string `json:"adminToken,omitempty"` // bearer token for /admin; the API is off when empty
+
+	WriteTimeout    Duration `json:"writeTimeout"`
+	PongTimeout     Duration `json:"pongTimeout"`
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+	RestartETA      Duration `json:"restartEta"`
+	RoomIdleTimeout Duration `json:"roomIdleTimeout"` // empty rooms are removed after this; 0 keeps them
+
+	ChatBlockedWords string `json:"chatBlockedWords,omitempty"` // comma-separated, masked in chat
+
+	Debug bool `json:"debug"` // serve /debug (needs the admin token)
+
+	LogLevel  string `json:"logLevel"`  // debug, info, warn or error
+	LogFormat string `json:"logFormat"` // text or json
+
+	Rules Rules `json:"rules"`
+}
+
+func Defaults() Config {
+	return Config{
+		Addr:            ":8081",
+		StatePath:       "monopoly-state.json",
+		WriteTimeout:    Duration(10 * time.Second),
+		PongTimeout:     Duration(60 * time.Second),
+		ShutdownTimeout: Duration(10 * time.Second),
+		RestartETA:      Duration(30 * time.Second),
+		RoomIdleTimeout: Duration(30 * time.Minute),
+		LogLevel:        "info",
+		LogFormat:       "text",
+		Rules:           Rules{MaxPlayers: 10},
+	}
+}
+
+// Load builds the configuration from args (usually os.Args[1:]).
+func Load(args []string) (Config, error) {
+	// First pass only to find -config; the second pass, after file and
+	// environment are applied, lets explicit flags win.
+	var probe Config
+	var path string
+	pre := flagSet(&probe, &path)
+	pre.SetOutput(nopWriter{})
+	if err := pre.Parse(args); err != nil {
+		// Parse again with output on so -h and mistakes print usage.
+		d := Defaults()
+		return Config{}, flagSet(&d, &path).Parse(args)
+	}
+
+	cfg := Defaults()
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return Config{}, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := flagSet(&cfg, &path).Parse(args); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.validate()
+}
+
+func flagSet(c *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("monopoly", flag.ContinueOnError)
+	fs.StringVar(path, "config", "", "JSON config file")
+	fs.StringVar(&c.Addr, "addr", c.Addr, "listen address")
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "TLS certificate file")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "TLS private key file")
+	fs.StringVar(&c.RedirectAddr, "redirect-addr", c.RedirectAddr, "with TLS, also listen here (e.g. :80) and redirect HTTP to HTTPS")
+	fs.StringVar(&c.StaticDir, "static-dir", c.StaticDir, "serve index.html and game.html from this directory instead of the embedded copies (frontend development)")
+	fs.StringVar(&c.StatePath, "state-file", c.StatePath, "room checkpoint file")
+	fs.StringVar(&c.AllowedOrigins, "allowed-origins", c.AllowedOrigins, "comma-separated browser origins allowed besides same-origin")
+	fs.DurationVar((*time.Duration)(&c.WriteTimeout), "write-timeout", time.Duration(c.WriteTimeout), "max time for one WebSocket write")
+	fs.DurationVar((*time.Duration)(&c.PongTimeout), "pong-timeout", time.Duration(c.PongTimeout), "drop connections silent for this long")
+	fs.DurationVar((*time.Duration)(&c.ShutdownTimeout), "shutdown-timeout", time.Duration(c.ShutdownTimeout), "max time for graceful shutdown")
+	fs.DurationVar((*time.Duration)(&c.RestartETA), "restart-eta", time.Duration(c.RestartETA), "reconnect delay announced on shutdown")
+	fs.BoolVar(&c.Debug, "debug", c.Debug, "serve /debug endpoints (pprof, runtime, room event logs) to the admin token")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "debug, info, warn or error")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "text or json")
+	fs.DurationVar((*time.Duration)(&c.RoomIdleTimeout), "room-idle-timeout", time.Duration(c.RoomIdleTimeout), "remove rooms that have been empty this long (0: never)")
+	fs.StringVar(&c.ChatBlockedWords, "chat-blocked-words", c.ChatBlockedWords, "comma-separated words masked in chat")
+	fs.IntVar(&c.Rules.MaxPlayers, "max-players", c.Rules.MaxPlayers, "players per room")
+	fs.BoolVar(&c.Rules.FairDice, "fair-dice", c.Rules.FairDice, "use commit-reveal dice in new rooms")
+	return fs
+}
+
+// applyEnv reads MONOPOLY_* variables. Secrets (session secret, admin token)
+// are only read from the file or the environment, never from flags.
+func applyEnv(c *Config) error {
+	str := map[string]*string{
+		"MONOPOLY_ADDR":               &c.Addr,
+		"MONOPOLY_TLS_CERT":           &c.TLSCert,
+		"MONOPOLY_TLS_KEY":            &c.TLSKey,
+		"MONOPOLY_REDIRECT_ADDR":      &c.RedirectAddr,
+		"MONOPOLY_STATIC_DIR":         &c.StaticDir,
+		"MONOPOLY_STATE_FILE":         &c.StatePath,
+		"MONOPOLY_ALLOWED_ORIGINS":    &c.AllowedOrigins,
+		"MONOPOLY_SESSION_SECRET":     &c.SessionSecret,
+		"MONOPOLY_ADMIN_TOKEN":        &c.AdminToken,
+		"MONOPOLY_LOG_LEVEL":          &c.LogLevel,
+		"MONOPOLY_LOG_FORMAT":         &c.LogFormat,
+		"MONOPOLY_CHAT_BLOCKED_WORDS": &c.ChatBlockedWords,
+	}
+	for key, dst := range str {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+
+	dur := map[string]*Duration{
+		"MONOPOLY_WRITE_TIMEOUT":     &c.WriteTimeout,
+		"MONOPOLY_PONG_TIMEOUT":      &c.PongTimeout,
+		"MONOPOLY_SHUTDOWN_TIMEOUT":  &c.ShutdownTimeout,
+		"MONOPOLY_RESTART_ETA":       &c.RestartETA,
+		"MONOPOLY_ROOM_IDLE_TIMEOUT": &c.RoomIdleTimeout,
+	}
+	for key, dst := range dur {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			*dst = Duration(d)
+		}
+	}
+
+	if v, ok := os.LookupEnv("MONOPOLY_MAX_PLAYERS"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("MONOPOLY_MAX_PLAYERS: %v", err)
+		}
+		c.Rules.MaxPlayers = n
+	}
+	if v, ok := os.LookupEnv("MONOPOLY_DEBUG"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("MONOPOLY_DEBUG: %v", err)
+		}
+		c.Debug = b
+	}
+	if v, ok := os.LookupEnv("MONOPOLY_FAIR_DICE"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("MONOPOLY_FAIR_DICE: %v", err)
+		}
+		c.Rules.FairDice = b
+	}
+	return nil
+}
+
+func (c Config) validate() error {
+	var level slog.Level
+	switch {
+	case level.UnmarshalText([]byte(c.LogLevel)) != nil:
+		return fmt.Errorf("unknown log level %q", c.LogLevel)
+	case c.LogFormat != "text" && c.LogFormat != "json":
+		return fmt.Errorf("unknown log format %q", c.LogFormat)
+	case c.Rules.MaxPlayers < 1:
+		return errors.New("max players must be at least 1")
+	case (c.TLSCert == "") != (c.TLSKey == ""):
+		return errors.New("tls cert and key must be set together")
+	case c.Debug && c.AdminToken == "":
+		return errors.New("debug endpoints need an admin token")
+	case c.RedirectAddr != "" && !c.TLS():
+		return errors.New("redirect addr needs a tls cert and key")
+	case c.WriteTimeout <= 0 || c.PongTimeout <= 0 || c.ShutdownTimeout <= 0:
+		return errors.New("timeouts must be positive")
+	case c.RoomIdleTimeout < 0 || c.RoomIdleTimeout > 0 && c.RoomIdleTimeout < Duration(time.Second):
+		return errors.New("room idle timeout must be 0 (never) or at least 1s")
+	}
+	return nil
+}
+
+// Level is the minimum level logged.
+func (c Config) Level() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// TLS reports whether the server should listen with TLS.
+func (c Config) TLS() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// Redacted is c with secrets blanked, safe to log.
+func (c Config) Redacted() Config {
+	if c.SessionSecret != "" {
+		c.SessionSecret = "<redacted>"
+	}
+	if c.AdminToken != "" {
+		c.AdminToken = "<redacted>"
+	}
+	return c
+}
+
+/* ===== Duration ===== */
+
+// Duration is a time.Duration written as "10s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
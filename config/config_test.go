@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a config file into a temporary directory.
+func writeFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "monopoly.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `{"addr": ":1000", "statePath": "file.json", "logLevel": "warn", "writeTimeout": "3s", "rules": {"maxPlayers": 4}}`)
+	t.Setenv("MONOPOLY_STATE_FILE", "env.json")
+	t.Setenv("MONOPOLY_LOG_LEVEL", "debug")
+	t.Setenv("MONOPOLY_MAX_PLAYERS", "6")
+
+	c, err := Load([]string{"-config", path, "-log-level", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want any
+	}{
+		{"addr from the file", c.Addr, ":1000"},
+		{"write timeout from the file", c.WriteTimeout, Duration(3 * time.Second)},
+		{"state path: env over file", c.StatePath, "env.json"},
+		{"max players: env over file", c.Rules.MaxPlayers, 6},
+		{"log level: flag over env", c.LogLevel, "error"},
+		{"log format default", c.LogFormat, "text"},
+		{"pong timeout default", c.PongTimeout, Defaults().PongTimeout},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unknown flag", []string{"-nope"}, nil, "not defined"},
+		{"missing file", []string{"-config", filepath.Join(t.TempDir(), "missing.json")}, nil, "no such file"},
+		{"bad env duration", nil, map[string]string{"MONOPOLY_PONG_TIMEOUT": "soon"}, "MONOPOLY_PONG_TIMEOUT"},
+		{"bad env bool", nil, map[string]string{"MONOPOLY_DEBUG": "maybe"}, "MONOPOLY_DEBUG"},
+		{"log level", []string{"-log-level", "loud"}, nil, "log level"},
+		{"log format", []string{"-log-format", "xml"}, nil, "log format"},
+		{"max players", []string{"-max-players", "0"}, nil, "max players"},
+		{"half a tls pair", []string{"-tls-cert", "cert.pem"}, nil, "tls cert and key"},
+		{"debug without admin token", []string{"-debug"}, nil, "admin token"},
+		{"redirect without tls", []string{"-redirect-addr", ":80"}, nil, "redirect addr"},
+		{"zero timeout", []string{"-write-timeout", "0s"}, nil, "timeouts"},
+		{"negative room idle", []string{"-room-idle-timeout", "-1m"}, nil, "room idle"},
+		{"tiny room idle", []string{"-room-idle-timeout", "1ms"}, nil, "room idle"},
+	}
+	t.Setenv("MONOPOLY_ADMIN_TOKEN", "") // whatever the shell has
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load(%q) = %v, want an error mentioning %q", tt.args, err, tt.want)
+			}
+		})
+	}
+
+	bad := writeFile(t, `{"writeTimeout": 10}`)
+	if _, err := Load([]string{"-config", bad}); err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("bad file: %v, want an error naming %s", err, bad)
+	}
+}
+
+func TestRoomIdleDisabled(t *testing.T) {
+	c, err := Load([]string{"-room-idle-timeout", "0"})
+	if err != nil || c.RoomIdleTimeout != 0 {
+		t.Errorf("Load = %v, %v; want 0 accepted as never", time.Duration(c.RoomIdleTimeout), err)
+	}
+}
+
+func TestSecretsNotFlags(t *testing.T) {
+	if _, err := Load([]string{"-admin-token", "x"}); err == nil {
+		t.Error("the admin token was accepted as a flag")
+	}
+	t.Setenv("MONOPOLY_ADMIN_TOKEN", "t0ken")
+	t.Setenv("MONOPOLY_SESSION_SECRET", "s3cret")
+	c, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AdminToken != "t0ken" || c.SessionSecret != "s3cret" {
+		t.Errorf("secrets from env = %q, %q", c.AdminToken, c.SessionSecret)
+	}
+	r := c.Redacted()
+	if r.AdminToken == "t0ken" || r.SessionSecret == "s3cret" {
+		t.Error("Redacted kept a secret")
+	}
+	if c.AdminToken != "t0ken" {
+		t.Error("Redacted changed the original")
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	b, err := json.Marshal(Duration(90 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `"1m30s"` {
+		t.Errorf("marshal = %s, want \"1m30s\"", b)
+	}
+	var d Duration
+	if err := json.Unmarshal(b, &d); err != nil || d != Duration(90*time.Second) {
+		t.Errorf("round trip = %v, %v", time.Duration(d), err)
+	}
+	if err := json.Unmarshal([]byte(`"ninety"`), &d); err == nil {
+		t.Error("unparseable duration accepted")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"monopoly/errcode"
+	"monopoly/game"
+)
+
+/* ===== Debug endpoints ===== */
+
+// registerDebug mounts the debug endpoints behind the admin token. They are
+// only registered when debugging is enabled in the config.
+func registerDebug(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/players", withAdmin(debugPlayersHTTP))
+	mux.HandleFunc("GET /debug/runtime", withAdmin(debugRuntimeHTTP))
+	mux.HandleFunc("GET /debug/rooms/{room}/events", withAdmin(debugEventsHTTP))
+
+	mux.HandleFunc("/debug/pprof/", withAdmin(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", withAdmin(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", withAdmin(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", withAdmin(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", withAdmin(pprof.Trace))
+}
+
+type PlayerInfo struct {
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	RoomID   string `json:"roomId"`
+	Pos      int    `json:"pos"`
+}
+
+// debugPlayersHTTP lists the connected players, optionally for one room.
+// Connections are copied under mu; positions are read and the response
+// encoded after it is released.
+func debugPlayersHTTP(w http.ResponseWriter, r *http.Request) {
+	room := r.URL.Query().Get("room")
+
+	type seat struct {
+		c *Client
+		g *game.Room
+	}
+	mu.Lock()
+	var seats []seat
+	for rm, set := range rooms {
+		if room != "" && rm != room {
+			continue
+		}
+		for c := range set {
+			seats = append(seats, seat{c, games[rm]})
+		}
+	}
+	mu.Unlock()
+
+	out := map[string][]PlayerInfo{}
+	for _, s := range seats {
+		info := PlayerInfo{PlayerID: s.c.ID, Name: s.c.Name, RoomID: s.g.ID, Pos: s.g.Position(s.c.ID)}
+		out[info.RoomID] = append(out[info.RoomID], info)
+	}
+	for _, list := range out {
+		sort.Slice(list, func(i, j int) bool { return list[i].PlayerID < list[j].PlayerID })
+	}
+
+	if room != "" {
+		writeJSON(w, map[string]any{"room": room, "players": out[room]})
+		return
+	}
+	writeJSON(w, out)
+}
+
+// debugRuntimeHTTP reports process-level counts.
+func debugRuntimeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	mu.Lock()
+	nRooms, nGames, nConns := len(rooms), len(games), len(conns)
+	mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"goroutines":  runtime.NumGoroutine(),
+		"connections": nConns,
+		"rooms":       nRooms,
+		"games":       nGames, // includes restored rooms nobody has rejoined
+		"heapBytes":   ms.HeapAlloc,
+		"gcCycles":    ms.NumGC,
+		"time":        time.Now(),
+	})
+}
+
+// debugEventsHTTP dumps a room's event log, from ?since=<seq> when given.
+func debugEventsHTTP(w http.ResponseWriter, r *http.Request) {
+	l := getEventLog(r.PathValue("room"))
+	if l == nil {
+		writeError(w, errorMsg("", errNoRoom))
+		return
+	}
+	var since uint64
+	if s := r.URL.Query().Get("since"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "since must be a sequence number")))
+			return
+		}
+		since = n
+	}
+	writeJSON(w, l.since(since))
+}
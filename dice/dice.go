@@ -0,0 +1,74 @@
+// Package dice provides the dice sources that drive a game. Every room owns
+// its own Dice so games can be seeded, replayed, or scripted in tests.
+package dice
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// Dice rolls a pair of six-sided dice.
+type Dice interface {
+	Roll() (int, int)
+}
+
+/* ===== Seeded ===== */
+
+// Seeded is a deterministic PRNG-backed Dice. Two Seeded values created with
+// the same seed produce the same sequence of rolls, so recording the seed is
+// enough to replay a game exactly.
+type Seeded struct {
+	mu   sync.Mutex
+	seed int64
+	rng  *rand.Rand
+}
+
+// NewSeeded returns dice whose sequence is fully determined by seed.
+func NewSeeded(seed int64) *Seeded {
+	return &Seeded{
+		seed: seed,
+		rng:  rand.New(rand.NewPCG(uint64(seed), 0)),
+	}
+}
+
+// NewRandom returns seeded dice with a fresh random seed.
+func NewRandom() *Seeded {
+	return NewSeeded(rand.Int64())
+}
+
+// Seed reports the seed the dice were created with.
+func (d *Seeded) Seed() int64 {
+	return d.seed
+}
+
+func (d *Seeded) Roll() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return 1 + d.rng.IntN(6), 1 + d.rng.IntN(6)
+}
+
+/* ===== Scripted ===== */
+
+// Scripted replays a fixed list of rolls, wrapping around when exhausted.
+// It is meant for tests that need a specific outcome.
+type Scripted struct {
+	mu    sync.Mutex
+	rolls [][2]int
+	next  int
+}
+
+// NewScripted returns dice that yield rolls in order.
+func NewScripted(rolls ...[2]int) *Scripted {
+	return &Scripted{rolls: rolls}
+}
+
+func (d *Scripted) Roll() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.rolls) == 0 {
+		return 1, 1
+	}
+	r := d.rolls[d.next%len(d.rolls)]
+	d.next++
+	return r[0], r[1]
+}
@@ -0,0 +1,49 @@
+package dice
+
+import "testing"
+
+func TestSeededDeterministic(t *testing.T) {
+	a, b := NewSeeded(42), NewSeeded(42)
+	other := NewSeeded(43)
+	same := true
+	for i := range 100 {
+		a1, a2 := a.Roll()
+		b1, b2 := b.Roll()
+		if a1 != b1 || a2 != b2 {
+			t.Fatalf("roll %d: seed 42 gave (%d,%d) and (%d,%d)", i, a1, a2, b1, b2)
+		}
+		if a1 < 1 || a1 > 6 || a2 < 1 || a2 > 6 {
+			t.Fatalf("roll %d: (%d,%d) out of range", i, a1, a2)
+		}
+		o1, o2 := other.Roll()
+		same = same && o1 == a1 && o2 == a2
+	}
+	if same {
+		t.Error("seeds 42 and 43 produced the same 100 rolls")
+	}
+	if got := a.Seed(); got != 42 {
+		t.Errorf("Seed() = %d, want 42", got)
+	}
+}
+
+func TestScripted(t *testing.T) {
+	tests := []struct {
+		name  string
+		rolls [][2]int
+		want  [][2]int
+	}{
+		{"in order", [][2]int{{1, 2}, {3, 4}}, [][2]int{{1, 2}, {3, 4}}},
+		{"wraps around", [][2]int{{6, 6}, {2, 5}}, [][2]int{{6, 6}, {2, 5}, {6, 6}, {2, 5}, {6, 6}}},
+		{"empty rolls snake eyes", nil, [][2]int{{1, 1}, {1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewScripted(tt.rolls...)
+			for i, want := range tt.want {
+				if d1, d2 := d.Roll(); d1 != want[0] || d2 != want[1] {
+					t.Errorf("roll %d = (%d,%d), want (%d,%d)", i, d1, d2, want[0], want[1])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package dice
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MaxEntropy caps the client entropy mixed into a single roll.
+const MaxEntropy = 128
+
+/* ===== Fair (commit-reveal) ===== */
+
+// Fair is a provably fair Dice. The server commits to sha256(seed) before the
+// first roll; every roll is derived from HMAC-SHA256(seed, "nonce:entropy")
+// where entropy is supplied by the rolling client. Once the seed is revealed,
+// anyone holding the Record can recompute every roll with Verify.
+type Fair struct {
+	mu    sync.Mutex
+	seed  []byte
+	rolls []Roll
+}
+
+// Roll is a single fair roll as recorded in the game log.
+type Roll struct {
+	Nonce   int    `json:"nonce"`
+	Entropy string `json:"entropy"`
+	Dice    [2]int `json:"dice"`
+}
+
+// Record is everything needed to verify a run of fair rolls.
+type Record struct {
+	Commitment string `json:"commitment"`
+	Seed       string `json:"seed"` // hex, empty until revealed
+	Rolls      []Roll `json:"rolls"`
+}
+
+// NewFair returns fair dice committed to a fresh random seed.
+func NewFair() *Fair {
+	return &Fair{seed: newFairSeed()}
+}
+
+// Commitment is the hex sha256 of the current (unrevealed) seed.
+func (f *Fair) Commitment() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return commit(f.seed)
+}
+
+func (f *Fair) Roll() (int, int) {
+	return f.RollWith("")
+}
+
+// RollWith rolls using the client-provided entropy. Entropy longer than
+// MaxEntropy bytes is truncated on a rune boundary, and invalid UTF-8 is
+// replaced, so the Record survives a JSON round trip and still verifies.
+func (f *Fair) RollWith(entropy string) (int, int) {
+	entropy = strings.ToValidUTF8(entropy, "\uFFFD")
+	if len(entropy) > MaxEntropy {
+		cut := MaxEntropy
+		for cut > 0 && !utf8.RuneStart(entropy[cut]) {
+			cut--
+		}
+		entropy = entropy[:cut]
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	nonce := len(f.rolls)
+	d1, d2 := fairRoll(f.seed, nonce, entropy)
+	f.rolls = append(f.rolls, Roll{Nonce: nonce, Entropy: entropy, Dice: [2]int{d1, d2}})
+	return d1, d2
+}
+
+// Reveal discloses the current seed together with every roll made under it,
+// then commits to a fresh seed for any later rolls.
+func (f *Fair) Reveal() Record {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rec := Record{
+		Commitment: commit(f.seed),
+		Seed:       hex.EncodeToString(f.seed),
+		Rolls:      f.rolls,
+	}
+	f.seed = newFairSeed()
+	f.rolls = nil
+	return rec
+}
+
+/* ===== Verification ===== */
+
+// Verify recomputes every roll in rec from its revealed seed and reports the
+// first discrepancy.
+func Verify(rec Record) error {
+	seed, err := hex.DecodeString(rec.Seed)
+	if err != nil || len(seed) == 0 {
+		return errors.New("seed missing or not hex")
+	}
+	if commit(seed) != rec.Commitment {
+		return errors.New("seed does not match commitment")
+	}
+	for i, r := range rec.Rolls {
+		if r.Nonce != i {
+			return fmt.Errorf("roll %d: nonce %d out of sequence", i, r.Nonce)
+		}
+		d1, d2 := fairRoll(seed, r.Nonce, r.Entropy)
+		if r.Dice != [2]int{d1, d2} {
+			return fmt.Errorf("roll %d: recorded %v, expected [%d %d]", i, r.Dice, d1, d2)
+		}
+	}
+	return nil
+}
+
+func commit(seed []byte) string {
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:])
+}
+
+func newFairSeed() []byte {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// fairRoll maps HMAC output bytes onto two dice, rejecting bytes >= 252 so
+// every face is equally likely.
+func fairRoll(seed []byte, nonce int, entropy string) (int, int) {
+	mac := hmac.New(sha256.New, seed)
+	fmt.Fprintf(mac, "%d:%s", nonce, entropy)
+	sum := mac.Sum(nil)
+
+	var out [2]int
+	n := 0
+	for n < 2 {
+		for _, b := range sum {
+			if b >= 252 {
+				continue
+			}
+			out[n] = 1 + int(b%6)
+			if n++; n == 2 {
+				break
+			}
+		}
+		next := sha256.Sum256(sum)
+		sum = next[:]
+	}
+	return out[0], out[1]
+}
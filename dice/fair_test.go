@@ -0,0 +1,150 @@
+package dice
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fairRecord rolls n times with distinct entropy and reveals the seed.
+func fairRecord(n int) Record {
+	f := NewFair()
+	for i := range n {
+		f.RollWith(strings.Repeat("x", i))
+	}
+	return f.Reveal()
+}
+
+func TestFairReveal(t *testing.T) {
+	f := NewFair()
+	commitment := f.Commitment()
+	d1, d2 := f.RollWith("abc")
+	rec := f.Reveal()
+	if rec.Commitment != commitment {
+		t.Errorf("revealed commitment %s, announced %s", rec.Commitment, commitment)
+	}
+	if len(rec.Rolls) != 1 || rec.Rolls[0].Dice != [2]int{d1, d2} || rec.Rolls[0].Entropy != "abc" {
+		t.Errorf("rolls = %+v, want one roll of [%d %d] with entropy abc", rec.Rolls, d1, d2)
+	}
+	if f.Commitment() == commitment {
+		t.Error("Reveal did not commit to a fresh seed")
+	}
+	if err := Verify(rec); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestFairEntropyTruncated(t *testing.T) {
+	f := NewFair()
+	f.RollWith(strings.Repeat("e", MaxEntropy+10))
+	rec := f.Reveal()
+	if got := len(rec.Rolls[0].Entropy); got != MaxEntropy {
+		t.Errorf("entropy length %d, want %d", got, MaxEntropy)
+	}
+	if err := Verify(rec); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestFairEntropyJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		entropy string
+		want    int // bytes kept
+	}{
+		{"3-byte runes over the limit", strings.Repeat("€", MaxEntropy/3+1), MaxEntropy / 3 * 3},
+		{"4-byte runes over the limit", strings.Repeat("🎲", MaxEntropy/4+1), MaxEntropy},
+		{"rune straddling the limit", strings.Repeat("a", MaxEntropy-1) + "é", MaxEntropy - 1},
+		{"exactly at the limit", strings.Repeat("a", MaxEntropy-2) + "é", MaxEntropy},
+		{"invalid UTF-8", "ok\xff\xfeok", len("ok\uFFFDok")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFair()
+			f.RollWith(tt.entropy)
+			b, err := json.Marshal(f.Reveal())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rec Record
+			if err := json.Unmarshal(b, &rec); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(rec.Rolls[0].Entropy); got != tt.want {
+				t.Errorf("kept %d bytes of entropy, want %d", got, tt.want)
+			}
+			if err := Verify(rec); err != nil {
+				t.Errorf("Verify after a JSON round trip: %v", err)
+			}
+		})
+	}
+}
+
+// otherEntropy finds entropy that changes the dice of roll i, since a
+// different entropy has a 1 in 36 chance of rolling the same pair.
+func otherEntropy(rec Record, i int) string {
+	seed, _ := hex.DecodeString(rec.Seed)
+	for n := 0; ; n++ {
+		e := "forged" + strconv.Itoa(n)
+		if d1, d2 := fairRoll(seed, i, e); [2]int{d1, d2} != rec.Rolls[i].Dice {
+			return e
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(*Record)
+		want   string // error substring, "" for a valid record
+	}{
+		{"untouched", func(*Record) {}, ""},
+		{"no rolls", func(r *Record) { r.Rolls = nil }, ""},
+		{"seed missing", func(r *Record) { r.Seed = "" }, "seed missing"},
+		{"seed not hex", func(r *Record) { r.Seed = "zz" + r.Seed[2:] }, "seed missing"},
+		{"other seed", func(r *Record) { r.Seed = strings.Repeat("ab", 32) }, "does not match commitment"},
+		{"other commitment", func(r *Record) { r.Commitment = strings.Repeat("0", 64) }, "does not match commitment"},
+		{"roll changed", func(r *Record) { r.Rolls[2].Dice = [2]int{r.Rolls[2].Dice[0]%6 + 1, r.Rolls[2].Dice[1]} }, "roll 2: recorded"},
+		{"entropy changed", func(r *Record) { r.Rolls[1].Entropy = otherEntropy(*r, 1) }, "roll 1: recorded"},
+		{"nonce skipped", func(r *Record) { r.Rolls[3].Nonce = 4 }, "roll 3: nonce 4 out of sequence"},
+		{"roll dropped", func(r *Record) { r.Rolls = append(r.Rolls[:1], r.Rolls[2:]...) }, "roll 1: nonce 2 out of sequence"},
+		{"rolls swapped", func(r *Record) { r.Rolls[0], r.Rolls[1] = r.Rolls[1], r.Rolls[0] }, "roll 0: nonce 1 out of sequence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := fairRecord(5)
+			rec.Rolls = append([]Roll(nil), rec.Rolls...)
+			tt.tamper(&rec)
+			err := Verify(rec)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Verify: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("Verify accepted a tampered record, want %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("Verify: %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFairRollDistribution(t *testing.T) {
+	var counts [7]int
+	seed := newFairSeed()
+	for nonce := range 6000 {
+		d1, d2 := fairRoll(seed, nonce, "")
+		counts[d1]++
+		counts[d2]++
+	}
+	for face := 1; face <= 6; face++ {
+		// 2000 expected per face; 1700 is more than 7 standard deviations out
+		if counts[face] < 1700 || counts[face] > 2300 {
+			t.Errorf("face %d rolled %d times in 12000 dice", face, counts[face])
+		}
+	}
+	if counts[0] != 0 {
+		t.Errorf("rolled a zero %d times", counts[0])
+	}
+}
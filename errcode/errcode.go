@@ -0,0 +1,73 @@
+// Package errcode is the catalog of error codes shared by every transport.
+// Codes are part of the public protocol: never rename one, only add.
+package errcode
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Code string
+
+const (
+	BadMessage        Code = "BAD_MESSAGE"        // malformed, unknown or invalid request
+	MethodNotAllowed  Code = "METHOD_NOT_ALLOWED" // wrong HTTP method
+	Unauthorized      Code = "UNAUTHORIZED"       // missing, expired or foreign session token
+	RateLimited       Code = "RATE_LIMITED"       // throttled; retry later
+	RoomFull          Code = "ROOM_FULL"          // no free seat in the room
+	NotSeated         Code = "NOT_SEATED"         // player is not seated in the room
+	NotYourTurn       Code = "NOT_YOUR_TURN"      // action requires holding the turn
+	InsufficientFunds Code = "INSUFFICIENT_FUNDS" // balance too low for the action
+	NotFound          Code = "NOT_FOUND"          // no such room, player or endpoint
+	Paused            Code = "PAUSED"             // the room is paused by an admin
+	Muted             Code = "MUTED"              // the host has muted this player's chat
+	NotHost           Code = "NOT_HOST"           // action requires being the room's host
+	Internal          Code = "INTERNAL"           // unexpected server failure
+)
+
+var status = map[Code]int{
+	BadMessage:        http.StatusBadRequest,
+	MethodNotAllowed:  http.StatusMethodNotAllowed,
+	Unauthorized:      http.StatusUnauthorized,
+	RateLimited:       http.StatusTooManyRequests,
+	RoomFull:          http.StatusConflict,
+	NotSeated:         http.StatusNotFound,
+	NotYourTurn:       http.StatusForbidden,
+	InsufficientFunds: http.StatusConflict,
+	NotFound:          http.StatusNotFound,
+	Paused:            http.StatusConflict,
+	Muted:             http.StatusForbidden,
+	NotHost:           http.StatusForbidden,
+	Internal:          http.StatusInternalServerError,
+}
+
+// HTTPStatus is the status code HTTP endpoints answer with for c.
+func (c Code) HTTPStatus() int {
+	if s, ok := status[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an error with a catalog code.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func New(code Code, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// From returns err as an *Error, wrapping anything uncatalogued as Internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return New(Internal, err.Error())
+}
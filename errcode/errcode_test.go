@@ -0,0 +1,52 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code Code
+		want int
+	}{
+		{BadMessage, http.StatusBadRequest},
+		{MethodNotAllowed, http.StatusMethodNotAllowed},
+		{Unauthorized, http.StatusUnauthorized},
+		{RateLimited, http.StatusTooManyRequests},
+		{RoomFull, http.StatusConflict},
+		{NotSeated, http.StatusNotFound},
+		{NotYourTurn, http.StatusForbidden},
+		{InsufficientFunds, http.StatusConflict},
+		{NotFound, http.StatusNotFound},
+		{Paused, http.StatusConflict},
+		{Muted, http.StatusForbidden},
+		{NotHost, http.StatusForbidden},
+		{Internal, http.StatusInternalServerError},
+		{"SOMETHING_NEW", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := tt.code.HTTPStatus(); got != tt.want {
+			t.Errorf("%s.HTTPStatus() = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+	if len(status) != len(tests)-1 {
+		t.Errorf("%d codes have a status, but the test covers %d", len(status), len(tests)-1)
+	}
+}
+
+func TestFrom(t *testing.T) {
+	notYours := New(NotYourTurn, "Not your turn.")
+	if got := From(notYours); got != notYours {
+		t.Errorf("From(*Error) = %v, want the same error", got)
+	}
+	if got := From(fmt.Errorf("roll: %w", notYours)); got != notYours {
+		t.Errorf("From(wrapped) = %v, want the wrapped *Error", got)
+	}
+	got := From(errors.New("disk on fire"))
+	if got.Code != Internal || got.Message != "disk on fire" {
+		t.Errorf("From(plain) = %+v, want INTERNAL keeping the message", got)
+	}
+}
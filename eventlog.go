@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+/* ===== Room event log ===== */
+
+// eventLogSize is how many broadcasts each room remembers: enough for the
+// recording of a long game.
+const eventLogSize = 5000
+
+// roomEvent is one message broadcast to a room, with the room version it
+// was sent at.
+type roomEvent struct {
+	Seq     uint64          `json:"seq"`
+	Version uint64          `json:"version"`
+	Time    time.Time       `json:"time"`
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message"`
+}
+
+// eventLog is a bounded, append-only record of a room's broadcasts. It
+// lives as long as the room's game.
+type eventLog struct {
+	mu     sync.Mutex
+	seq    uint64
+	events []roomEvent                // ring of at most eventLogSize
+	head   int                        // index of the oldest event once full
+	subs   map[chan struct{}]struct{} // poked after every append
+}
+
+func (l *eventLog) append(version uint64, typ string, msg []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	e := roomEvent{Seq: l.seq, Version: version, Time: time.Now(), Type: typ, Message: msg}
+	if len(l.events) < eventLogSize {
+		l.events = append(l.events, e)
+	} else {
+		l.events[l.head] = e
+		l.head = (l.head + 1) % eventLogSize
+	}
+	for ch := range l.subs {
+		select {
+		case ch <- struct{}{}:
+		default: // already pending
+		}
+	}
+}
+
+// subscribe returns a channel that receives a value whenever events have
+// been appended since the last receive.
+func (l *eventLog) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	if l.subs == nil {
+		l.subs = make(map[chan struct{}]struct{})
+	}
+	l.subs[ch] = struct{}{}
+	l.mu.Unlock()
+	return ch
+}
+
+func (l *eventLog) unsubscribe(ch chan struct{}) {
+	l.mu.Lock()
+	delete(l.subs, ch)
+	l.mu.Unlock()
+}
+
+// since returns a copy of the events with Seq > seq, oldest first.
+func (l *eventLog) since(seq uint64) []roomEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.events)
+	if seq >= l.seq || n == 0 {
+		return nil
+	}
+	// Sequence numbers are consecutive, so the oldest held is l.seq-n+1.
+	skip := 0
+	if first := l.seq - uint64(n) + 1; seq >= first {
+		skip = int(seq - first + 1)
+	}
+	out := make([]roomEvent, 0, n-skip)
+	for i := skip; i < n; i++ {
+		out = append(out, l.events[(l.head+i)%n])
+	}
+	return out
+}
+
+// covers reports whether every event after seq is still in the log.
+func (l *eventLog) covers(seq uint64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if seq > l.seq {
+		return false // from an earlier log
+	}
+	return len(l.events) == 0 || l.events[l.head].Seq <= seq+1
+}
+
+// last is the sequence number of the newest event, 0 if none.
+func (l *eventLog) last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// getEventLog returns the room's log, or nil if the room is not open.
+func getEventLog(room string) *eventLog {
+	mu.Lock()
+	defer mu.Unlock()
+	return eventLogs[room]
+}
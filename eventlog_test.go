@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// seqs lists the sequence numbers of events.
+func seqs(events []roomEvent) []uint64 {
+	out := make([]uint64, len(events))
+	for i, e := range events {
+		out[i] = e.Seq
+	}
+	return out
+}
+
+// consecutive reports whether s counts up by one from first.
+func consecutive(s []uint64, first uint64) bool {
+	for i, n := range s {
+		if n != first+uint64(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEventLogWraps(t *testing.T) {
+	var l eventLog
+	if l.since(0) != nil || !l.covers(0) || l.last() != 0 {
+		t.Fatal("empty log is not empty")
+	}
+	ch := l.subscribe()
+	defer l.unsubscribe(ch)
+
+	const total = eventLogSize*2 + 7
+	for i := 1; i <= total; i++ {
+		l.append(uint64(i), "test", []byte(strconv.Itoa(i)))
+		if i == 3 {
+			got := seqs(l.since(1))
+			if len(got) != 2 || !consecutive(got, 2) {
+				t.Fatalf("before wrapping, since(1) = %v", got)
+			}
+		}
+	}
+	select {
+	case <-ch:
+	default:
+		t.Error("subscriber not poked")
+	}
+
+	oldest := uint64(total - eventLogSize + 1)
+	all := l.since(0)
+	if s := seqs(all); len(s) != eventLogSize || !consecutive(s, oldest) {
+		t.Fatalf("since(0) holds %d events from %d, want %d from %d", len(s), s[0], eventLogSize, oldest)
+	}
+	if string(all[0].Message) != strconv.FormatUint(oldest, 10) || all[0].Version != oldest {
+		t.Errorf("oldest event = %+v", all[0])
+	}
+	if s := seqs(l.since(total - 3)); len(s) != 3 || !consecutive(s, total-2) {
+		t.Errorf("since(total-3) = %v", s)
+	}
+	if s := l.since(total); len(s) != 0 {
+		t.Errorf("since(last) = %v, want nothing", seqs(s))
+	}
+	if l.last() != total {
+		t.Errorf("last = %d, want %d", l.last(), total)
+	}
+
+	for _, tt := range []struct {
+		seq  uint64
+		want bool
+	}{
+		{0, false},
+		{oldest - 2, false},
+		{oldest - 1, true}, // everything after it is held
+		{total, true},
+		{total + 1, false}, // from an earlier log
+	} {
+		if got := l.covers(tt.seq); got != tt.want {
+			t.Errorf("covers(%d) = %v, want %v", tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestDebugGated(t *testing.T) {
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	get := func(srvURL, token string) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srvURL+"/debug/runtime", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	cfg.Debug, cfg.AdminToken = false, "adm1n"
+	if code := get(startTestServer(t).URL, "adm1n"); code != http.StatusNotFound {
+		t.Errorf("debug off: %d, want 404", code)
+	}
+
+	cfg.Debug = true
+	srv := startTestServer(t)
+	if code := get(srv.URL, ""); code != http.StatusUnauthorized {
+		t.Errorf("no token: %d, want 401", code)
+	}
+	if code := get(srv.URL, "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d, want 401", code)
+	}
+	if code := get(srv.URL, "adm1n"); code != http.StatusOK {
+		t.Errorf("admin token: %d, want 200", code)
+	}
+
+	room := newLobbyRoom(t, "")
+	c := newTestConn(t)
+	seat(t, c, room, "p1", "")
+	req, _ := http.NewRequest("GET", srv.URL+"/debug/rooms/"+room+"/events?since=1", nil)
+	req.Header.Set("Authorization", "Bearer adm1n")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var events []roomEvent
+	if err := json.NewDecoder(res.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+	if want := getEventLog(room).since(1); len(events) != len(want) || len(events) > 0 && events[0].Seq != 2 {
+		t.Errorf("events since 1 = %v, want %v", seqs(events), seqs(want))
+	}
+}
@@ -0,0 +1,72 @@
+package game
+
+// TileKind classifies the tiles of the board.
+type TileKind string
+
+const (
+	KindCorner   TileKind = "corner"
+	KindStreet   TileKind = "street"
+	KindRailroad TileKind = "railroad"
+	KindUtility  TileKind = "utility"
+	KindTax      TileKind = "tax"
+	KindChance   TileKind = "chance"
+	KindChest    TileKind = "chest"
+)
+
+type Tile struct {
+	Index int      `json:"index"`
+	Name  string   `json:"name"`
+	Kind  TileKind `json:"kind"`
+	Group string   `json:"group,omitempty"` // colour set of a street
+}
+
+// Board is the standard board, GO first and clockwise, as drawn by
+// game.html.
+var Board = func() [BoardSize]Tile {
+	tiles := [BoardSize]Tile{
+		{Name: "GO", Kind: KindCorner},
+		{Name: "Mediterranean Avenue", Kind: KindStreet, Group: "brown"},
+		{Name: "Community Chest", Kind: KindChest},
+		{Name: "Baltic Avenue", Kind: KindStreet, Group: "brown"},
+		{Name: "Income Tax", Kind: KindTax},
+		{Name: "Reading Railroad", Kind: KindRailroad},
+		{Name: "Oriental Avenue", Kind: KindStreet, Group: "lightBlue"},
+		{Name: "Chance", Kind: KindChance},
+		{Name: "Vermont Avenue", Kind: KindStreet, Group: "lightBlue"},
+		{Name: "Connecticut Avenue", Kind: KindStreet, Group: "lightBlue"},
+		{Name: "Jail / Just Visiting", Kind: KindCorner},
+		{Name: "St. Charles Place", Kind: KindStreet, Group: "pink"},
+		{Name: "Electric Company", Kind: KindUtility},
+		{Name: "States Avenue", Kind: KindStreet, Group: "pink"},
+		{Name: "Virginia Avenue", Kind: KindStreet, Group: "pink"},
+		{Name: "Pennsylvania Railroad", Kind: KindRailroad},
+		{Name: "St. James Place", Kind: KindStreet, Group: "orange"},
+		{Name: "Community Chest", Kind: KindChest},
+		{Name: "Tennessee Avenue", Kind: KindStreet, Group: "orange"},
+		{Name: "New York Avenue", Kind: KindStreet, Group: "orange"},
+		{Name: "Free Parking", Kind: KindCorner},
+		{Name: "Kentucky Avenue", Kind: KindStreet, Group: "red"},
+		{Name: "Chance", Kind: KindChance},
+		{Name: "Indiana Avenue", Kind: KindStreet, Group: "red"},
+		{Name: "Illinois Avenue", Kind: KindStreet, Group: "red"},
+		{Name: "B. & O. Railroad", Kind: KindRailroad},
+		{Name: "Atlantic Avenue", Kind: KindStreet, Group: "yellow"},
+		{Name: "Ventnor Avenue", Kind: KindStreet, Group: "yellow"},
+		{Name: "Water Works", Kind: KindUtility},
+		{Name: "Marvin Gardens", Kind: KindStreet, Group: "yellow"},
+		{Name: "Go To Jail", Kind: KindCorner},
+		{Name: "Pacific Avenue", Kind: KindStreet, Group: "green"},
+		{Name: "North Carolina Avenue", Kind: KindStreet, Group: "green"},
+		{Name: "Community Chest", Kind: KindChest},
+		{Name: "Pennsylvania Avenue", Kind: KindStreet, Group: "green"},
+		{Name: "Short Line", Kind: KindRailroad},
+		{Name: "Chance", Kind: KindChance},
+		{Name: "Park Place", Kind: KindStreet, Group: "darkBlue"},
+		{Name: "Luxury Tax", Kind: KindTax},
+		{Name: "Boardwalk", Kind: KindStreet, Group: "darkBlue"},
+	}
+	for i := range tiles {
+		tiles[i].Index = i
+	}
+	return tiles
+}()
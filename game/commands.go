@@ -0,0 +1,97 @@
+package game
+
+import (
+	"monopoly/dice"
+	"monopoly/errcode"
+)
+
+// Rule violations returned by commands. Transports map the codes onto their
+// own status codes and reply formats.
+var (
+	ErrNotSeated   = errcode.New(errcode.NotSeated, "player is not seated in this room")
+	ErrNotYourTurn = errcode.New(errcode.NotYourTurn, "Not your turn.")
+	ErrPaused      = errcode.New(errcode.Paused, "The game is paused.")
+)
+
+/* ===== Roll ===== */
+
+// RollResult describes a completed roll and the move it caused.
+type RollResult struct {
+	PlayerID string
+	Dice     [2]int
+	Total    int
+	From, To int
+	NextTurn string // playerID now holding the turn
+	Version  uint64 // room version after the roll
+}
+
+// Roll rolls the dice for playerID, moves their token and passes the turn.
+// entropy is only used by fair dice.
+func (r *Room) Roll(playerID, entropy string) (RollResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.players[playerID]; !ok {
+		return RollResult{}, ErrNotSeated
+	}
+	if r.paused {
+		return RollResult{}, ErrPaused
+	}
+	if r.turn != playerID {
+		return RollResult{}, ErrNotYourTurn
+	}
+
+	var d1, d2 int
+	if f, ok := r.dice.(*dice.Fair); ok {
+		d1, d2 = f.RollWith(entropy)
+	} else {
+		d1, d2 = r.dice.Roll()
+	}
+	total := d1 + d2
+
+	from := r.positions[playerID]
+	to := (from + total) % BoardSize
+	r.positions[playerID] = to
+	r.version++
+
+	r.advanceLocked()
+
+	return RollResult{
+		PlayerID: playerID,
+		Dice:     [2]int{d1, d2},
+		Total:    total,
+		From:     from,
+		To:       to,
+		NextTurn: r.turn,
+		Version:  r.version,
+	}, nil
+}
+
+/* ===== Moderation ===== */
+
+// ForcePass takes the turn from its holder and hands it to the next player,
+// as if they had rolled. It reports the previous and new holders.
+func (r *Room) ForcePass() (from, to string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.players) == 0 {
+		return "", "", ErrNotSeated
+	}
+	from = r.turn
+	r.advanceLocked()
+	r.version++
+	return from, r.turn, nil
+}
+
+// SetPaused pauses or resumes the room. While paused every player command
+// fails with ErrPaused. It reports whether anything changed.
+func (r *Room) SetPaused(paused bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.paused == paused {
+		return false
+	}
+	r.paused = paused
+	r.version++
+	return true
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"monopoly/dice"
+)
+
+// newTestRoom seats players in order; the first one holds the turn.
+func newTestRoom(d dice.Dice, players ...Player) *Room {
+	r := NewRoom("TEST", d)
+	for _, p := range players {
+		r.Join(p)
+	}
+	return r
+}
+
+var (
+	alice = Player{ID: "a", Name: "Alice"}
+	bob   = Player{ID: "b", Name: "Bob"}
+	carol = Player{ID: "c", Name: "Carol"}
+)
+
+func TestRollTurnOrder(t *testing.T) {
+	r := newTestRoom(dice.NewScripted([2]int{1, 2}, [2]int{3, 3}, [2]int{6, 5}), carol, alice, bob)
+
+	// The first player seated starts; the turn then follows name order.
+	steps := []struct {
+		player   string
+		dice     [2]int
+		from, to int
+		next     string
+	}{
+		{"c", [2]int{1, 2}, 0, 3, "a"},
+		{"a", [2]int{3, 3}, 0, 6, "b"},
+		{"b", [2]int{6, 5}, 0, 11, "c"},
+		{"c", [2]int{1, 2}, 3, 6, "a"},
+	}
+	for i, s := range steps {
+		res, err := r.Roll(s.player, "")
+		if err != nil {
+			t.Fatalf("step %d: Roll(%s): %v", i, s.player, err)
+		}
+		if res.Dice != s.dice || res.Total != s.dice[0]+s.dice[1] {
+			t.Errorf("step %d: dice %v total %d, want %v", i, res.Dice, res.Total, s.dice)
+		}
+		if res.From != s.from || res.To != s.to {
+			t.Errorf("step %d: moved %d->%d, want %d->%d", i, res.From, res.To, s.from, s.to)
+		}
+		if res.NextTurn != s.next || r.Turn() != s.next {
+			t.Errorf("step %d: next turn %q, want %q", i, res.NextTurn, s.next)
+		}
+	}
+}
+
+func TestRollWrapsAroundBoard(t *testing.T) {
+	r := newTestRoom(dice.NewScripted([2]int{6, 6}), alice)
+	for range 3 {
+		if _, err := r.Roll("a", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := r.Roll("a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.From != 36 || res.To != 8 {
+		t.Errorf("moved %d->%d, want 36->8", res.From, res.To)
+	}
+}
+
+func TestRollErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		paused bool
+		player string
+		want   error
+	}{
+		{"not seated", false, "z", ErrNotSeated},
+		{"not your turn", false, "b", ErrNotYourTurn},
+		{"paused", true, "a", ErrPaused},
+		{"paused beats turn order", true, "b", ErrPaused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRoom(dice.NewScripted([2]int{2, 3}), alice, bob)
+			r.SetPaused(tt.paused)
+			before := r.Version()
+			_, err := r.Roll(tt.player, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Roll(%s) = %v, want %v", tt.player, err, tt.want)
+			}
+			if r.Version() != before || r.Turn() != "a" || r.Position("a") != 0 {
+				t.Error("a refused roll changed the room")
+			}
+		})
+	}
+}
+
+func TestRollAfterResume(t *testing.T) {
+	r := newTestRoom(dice.NewScripted([2]int{2, 3}), alice, bob)
+	r.SetPaused(true)
+	r.SetPaused(false)
+	res, err := r.Roll("a", "")
+	if err != nil {
+		t.Fatalf("Roll after resume: %v", err)
+	}
+	if res.To != 5 || res.NextTurn != "b" {
+		t.Errorf("got to=%d next=%q, want 5 and b", res.To, res.NextTurn)
+	}
+}
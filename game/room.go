@@ -0,0 +1,295 @@
+// Package game holds the server-authoritative state of each room and the
+// commands that change it. Transports (HTTP, WebSocket) call into a Room and
+// turn the typed results and errors into their own wire formats.
+package game
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"monopoly/dice"
+)
+
+// BoardSize is the number of tiles on the board.
+const BoardSize = 40
+
+// Phase is where a room is in its lifecycle.
+type Phase string
+
+const (
+	PhaseWaiting Phase = "waiting" // nobody seated
+	PhasePlaying Phase = "playing"
+	PhasePaused  Phase = "paused" // commands are refused until resumed
+)
+
+type Player struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Room is one game: the seated players, their positions, whose turn it is
+// and the dice that drive it. All methods are safe for concurrent use.
+type Room struct {
+	ID      string
+	Started time.Time // when this instance was created or restored
+
+	mu        sync.Mutex
+	dice      dice.Dice
+	players   map[string]Player // playerID -> player
+	positions map[string]int    // playerID -> tile index (0..39)
+	turn      string            // playerID of the current turn holder
+	host      string            // playerID who moderates the room
+	paused    bool
+	version   uint64 // bumped by every change to the above
+}
+
+func NewRoom(id string, d dice.Dice) *Room {
+	return &Room{
+		ID:        id,
+		Started:   time.Now(),
+		dice:      d,
+		players:   make(map[string]Player),
+		positions: make(map[string]int),
+	}
+}
+
+// Dice returns the room's dice source.
+func (r *Room) Dice() dice.Dice {
+	return r.dice
+}
+
+/* ===== Seats ===== */
+
+// Join seats p (or renames an already seated player). New players start on
+// GO; returning players keep their position. It reports whether p was
+// handed the turn because nobody held it. The first player seated becomes
+// the host.
+func (r *Room) Join(p Player) (gotTurn bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.players[p.ID] = p
+	r.version++
+	if _, ok := r.positions[p.ID]; !ok {
+		r.positions[p.ID] = 0 // GO for brand new players
+	}
+	if r.host == "" {
+		r.host = p.ID
+	}
+	if r.turn == "" {
+		r.turn = p.ID
+		return true
+	}
+	return false
+}
+
+// Leave unseats playerID. If they held the turn it passes to the next
+// player and Leave reports the new holder ("" if the room is now empty).
+// A leaving host hands over to the first remaining player.
+func (r *Room) Leave(playerID string) (next string, turnChanged bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.players[playerID]; !ok {
+		return r.turn, false
+	}
+	r.version++
+	if r.host == playerID {
+		defer r.rehostLocked()
+	}
+	if r.turn != playerID {
+		delete(r.players, playerID)
+		return r.turn, false
+	}
+	r.advanceLocked()
+	delete(r.players, playerID)
+	if r.turn == playerID {
+		r.turn = ""
+	}
+	return r.turn, true
+}
+
+// Players returns the seated players sorted by Name, then ID.
+func (r *Room) Players() []Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sortedLocked()
+}
+
+// Player returns the seated player with the given ID.
+func (r *Room) Player(playerID string) (Player, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.players[playerID]
+	return p, ok
+}
+
+// Known reports whether playerID has ever been seated here, even if they
+// have since left.
+func (r *Room) Known(playerID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.positions[playerID]
+	return ok
+}
+
+// Turn returns the playerID holding the turn, or "" if none.
+func (r *Room) Turn() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.turn
+}
+
+// Host returns the playerID of the room's host, or "" if nobody is seated.
+func (r *Room) Host() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.host
+}
+
+// Phase reports whether the room is waiting for players, playing or paused.
+func (r *Room) Phase() Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.phaseLocked()
+}
+
+func (r *Room) phaseLocked() Phase {
+	switch {
+	case r.paused:
+		return PhasePaused
+	case len(r.players) == 0:
+		return PhaseWaiting
+	}
+	return PhasePlaying
+}
+
+// Version counts the changes made to the room. It only ever increases,
+// including across checkpoints.
+func (r *Room) Version() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+func (r *Room) Position(playerID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.positions[playerID]
+}
+
+// View is a consistent copy of what the players see.
+type View struct {
+	Players   []Player
+	Positions map[string]int // seated players only
+	Turn      string
+	Host      string
+	Phase     Phase
+	Version   uint64
+}
+
+// View captures the seated players, positions and turn in one step.
+func (r *Room) View() View {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pos := make(map[string]int, len(r.players))
+	for id := range r.players {
+		pos[id] = r.positions[id]
+	}
+	return View{Players: r.sortedLocked(), Positions: pos, Turn: r.turn, Host: r.host, Phase: r.phaseLocked(), Version: r.version}
+}
+
+// Positions returns a copy of every seated player's position.
+func (r *Room) Positions() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := make(map[string]int, len(r.players))
+	for id := range r.players {
+		cp[id] = r.positions[id]
+	}
+	return cp
+}
+
+/* ===== Turns ===== */
+
+func (r *Room) sortedLocked() []Player {
+	out := make([]Player, 0, len(r.players))
+	for _, p := range r.players {
+		out = append(out, p)
+	}
+	// Sort for stable order (by Name then ID) so rotation is stable
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name == out[j].Name {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// advanceLocked hands the turn to the player after the current holder.
+func (r *Room) advanceLocked() {
+	list := r.sortedLocked()
+	if len(list) == 0 {
+		r.turn = ""
+		return
+	}
+	next := 0
+	for i, p := range list {
+		if p.ID == r.turn {
+			next = (i + 1) % len(list)
+			break
+		}
+	}
+	r.turn = list[next].ID
+}
+
+// rehostLocked makes the first seated player host. Deferred by Leave, so it
+// runs once the leaver is gone.
+func (r *Room) rehostLocked() {
+	r.host = ""
+	if list := r.sortedLocked(); len(list) > 0 {
+		r.host = list[0].ID
+	}
+}
+
+/* ===== Checkpoints ===== */
+
+// Snapshot is the persistent part of a room. Dice are not included: a
+// restored room rolls with fresh dice.
+type Snapshot struct {
+	ID        string         `json:"id"`
+	Players   []Player       `json:"players"`
+	Positions map[string]int `json:"positions"`
+	Turn      string         `json:"turn,omitempty"`
+	Paused    bool           `json:"paused,omitempty"`
+	Version   uint64         `json:"version"`
+}
+
+// Snapshot captures the room for a checkpoint.
+func (r *Room) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pos := make(map[string]int, len(r.positions))
+	for id, p := range r.positions {
+		pos[id] = p
+	}
+	return Snapshot{ID: r.ID, Players: r.sortedLocked(), Positions: pos, Turn: r.turn, Paused: r.paused, Version: r.version}
+}
+
+// Restore rebuilds a room from a checkpoint. Nobody is seated until they
+// resume; everyone keeps their position and the first player back gets the
+// turn and hosts, as in a fresh room.
+func Restore(s Snapshot, d dice.Dice) *Room {
+	r := NewRoom(s.ID, d)
+	r.version = s.Version
+	r.paused = s.Paused
+	for id, p := range s.Positions {
+		r.positions[id] = p
+	}
+	for _, p := range s.Players {
+		if _, ok := r.positions[p.ID]; !ok {
+			r.positions[p.ID] = 0
+		}
+	}
+	return r
+}
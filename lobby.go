@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"monopoly/errcode"
+	"monopoly/game"
+)
+
+/* ===== Lobby ===== */
+
+// roomMeta describes a room created through POST /rooms. Its ID doubles as
+// the join code. Rooms exist until they have been empty for roomIdle.
+type roomMeta struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Public       bool      `json:"public"` // listed by GET /rooms
+	PasswordSalt []byte    `json:"passwordSalt,omitempty"`
+	PasswordHash []byte    `json:"passwordHash,omitempty"`
+	Created      time.Time `json:"created"`
+	LastActive   time.Time `json:"lastActive"`
+	Replay       bool      `json:"replay,omitempty"` // read-only, see startReplay
+
+	// Kicked lists the playerIDs an admin removed; their session tokens no
+	// longer resume a seat. Replaced, never modified, so copies stay valid.
+	Kicked map[string]bool `json:"kicked,omitempty"`
+}
+
+var (
+	// lobby holds every room that can be joined, guarded by mu.
+	lobby = make(map[string]*roomMeta)
+
+	// roomIdle is how long a room may sit empty before it is removed; 0
+	// means never.
+	roomIdle = 30 * time.Minute
+)
+
+const (
+	codeAlphabet   = "ABCDEFGHJKMNPQRSTUVWXYZ23456789" // no 0/O, 1/I/L
+	codeLength     = 6
+	maxRoomName    = 40
+	maxPasswordLen = 128
+
+	// PBKDF2-SHA256 rounds for room passwords, about 20ms per check.
+	passwordIterations = 100_000
+)
+
+var errNoRoomCode = errcode.New(errcode.NotFound, "No room with that code.")
+
+// newJoinCode draws codeLength letters uniformly from codeAlphabet,
+// rejecting random bytes past the largest multiple of its length.
+func newJoinCode() string {
+	limit := 256 - 256%len(codeAlphabet)
+	code := make([]byte, 0, codeLength)
+	b := make([]byte, codeLength)
+	for len(code) < codeLength {
+		_, _ = rand.Read(b)
+		for _, c := range b {
+			if int(c) < limit && len(code) < codeLength {
+				code = append(code, codeAlphabet[int(c)%len(codeAlphabet)])
+			}
+		}
+	}
+	return string(code)
+}
+
+func (m *roomMeta) setPassword(pw string) {
+	if pw == "" {
+		return
+	}
+	m.PasswordSalt = make([]byte, 16)
+	_, _ = rand.Read(m.PasswordSalt)
+	m.PasswordHash = hashPassword(m.PasswordSalt, pw)
+}
+
+func (m *roomMeta) hasPassword() bool {
+	return len(m.PasswordHash) > 0
+}
+
+func (m *roomMeta) checkPassword(pw string) bool {
+	return subtle.ConstantTimeCompare(hashPassword(m.PasswordSalt, pw), m.PasswordHash) == 1
+}
+
+// hashPassword stretches pw with PBKDF2 so a leaked checkpoint does not
+// give room passwords away to a fast guesser.
+func hashPassword(salt []byte, pw string) []byte {
+	key, err := pbkdf2.Key(sha256.New, pw, salt, passwordIterations, sha256.Size)
+	if err != nil {
+		panic(err) // only for out-of-range key lengths
+	}
+	return key
+}
+
+// createRoom registers a room under a fresh join code.
+func createRoom(name string, public bool, password string) roomMeta {
+	now := time.Now()
+	m := &roomMeta{Name: name, Public: public, Created: now, LastActive: now}
+	m.setPassword(password)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for {
+		m.ID = newJoinCode()
+		if lobby[m.ID] == nil && games[m.ID] == nil {
+			break
+		}
+	}
+	if m.Name == "" {
+		m.Name = "Room " + m.ID
+	}
+	lobby[m.ID] = m
+	return *m
+}
+
+// getRoomMeta returns a copy of the room's lobby entry.
+func getRoomMeta(room string) (roomMeta, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	m := lobby[room]
+	if m == nil {
+		return roomMeta{}, false
+	}
+	return *m, true
+}
+
+// touchRoomLocked marks the room as in use now. Callers hold mu.
+func touchRoomLocked(room string) {
+	if m := lobby[room]; m != nil {
+		m.LastActive = time.Now()
+	}
+}
+
+// expireRooms removes rooms that have been empty for roomIdle, including
+// rooms restored from a checkpoint that nobody came back to. A roomIdle of
+// zero keeps rooms forever.
+func expireRooms(ctx context.Context) {
+	if roomIdle <= 0 {
+		return
+	}
+	t := time.NewTicker(max(time.Second, min(time.Minute, roomIdle/2)))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			var expired []string
+			mu.Lock()
+			for id, m := range lobby {
+				if len(rooms[id]) == 0 && now.Sub(m.LastActive) >= roomIdle {
+					delete(lobby, id)
+					delete(games, id)
+					delete(eventLogs, id)
+					delete(chats, id)
+					delete(reveals, id)
+					endReplayLocked(id)
+					expired = append(expired, id)
+				}
+			}
+			mu.Unlock()
+			for _, id := range expired {
+				roomLog(id).Info("room expired", "idle", roomIdle)
+				emitHook(hookRoomClosed, id, map[string]any{"reason": "idle"})
+			}
+		}
+	}
+}
+
+/* ===== REST: /rooms ===== */
+
+type roomListing struct {
+	Code              string     `json:"code"`
+	Name              string     `json:"name"`
+	Public            bool       `json:"public"`
+	PasswordProtected bool       `json:"passwordProtected"`
+	Players           int        `json:"players"`
+	MaxPlayers        int        `json:"maxPlayers"`
+	Phase             game.Phase `json:"phase"`
+	Created           time.Time  `json:"created"`
+}
+
+func roomsHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listRoomsHTTP(w, r)
+	case http.MethodPost:
+		createRoomHTTP(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, errorMsg("", errMethodNotAllowed))
+	}
+}
+
+// createRoomHTTP answers 201 with the new room's join code.
+func createRoomHTTP(w http.ResponseWriter, r *http.Request) {
+	if ok, retry := allow(ipLimit, "ip", remoteIP(r)); !ok {
+		writeError(w, rateLimitedMsg("", retry))
+		return
+	}
+	req := struct {
+		Name     string `json:"name"`
+		Public   *bool  `json:"public"` // default true
+		Password string `json:"password"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "bad json")))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(req.Name) > maxRoomName || len(req.Password) > maxPasswordLen {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "name or password too long")))
+		return
+	}
+	public := req.Public == nil || *req.Public
+
+	m := createRoom(req.Name, public, req.Password)
+	roomLog(m.ID).Info("room created", "public", public, "password", m.hasPassword())
+	emitHook(hookRoomCreated, m.ID, map[string]any{"name": m.Name, "public": public})
+
+	w.Header().Set("Location", "/rooms/"+m.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(listing(m, nil))
+}
+
+// listRoomsHTTP lists the public rooms, busiest first.
+func listRoomsHTTP(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		meta roomMeta
+		g    *game.Room
+	}
+	mu.Lock()
+	var list []entry
+	for id, m := range lobby {
+		if m.Public {
+			list = append(list, entry{*m, games[id]})
+		}
+	}
+	mu.Unlock()
+
+	out := make([]roomListing, 0, len(list))
+	for _, e := range list {
+		out = append(out, listing(e.meta, e.g))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Players != out[j].Players {
+			return out[i].Players > out[j].Players
+		}
+		return out[i].Created.Before(out[j].Created)
+	})
+	writeJSON(w, out)
+}
+
+func listing(m roomMeta, g *game.Room) roomListing {
+	l := roomListing{
+		Code:              m.ID,
+		Name:              m.Name,
+		Public:            m.Public,
+		PasswordProtected: m.hasPassword(),
+		MaxPlayers:        maxPlayers,
+		Phase:             game.PhaseWaiting,
+		Created:           m.Created,
+	}
+	if g != nil {
+		l.Players = len(g.Players())
+		l.Phase = g.Phase()
+	}
+	return l
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"monopoly/errcode"
+	"monopoly/protocol"
+)
+
+func TestNewJoinCode(t *testing.T) {
+	counts := make(map[rune]int)
+	const n = 5000
+	for range n {
+		code := newJoinCode()
+		if len(code) != codeLength {
+			t.Fatalf("code %q is not %d letters", code, codeLength)
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(codeAlphabet, c) {
+				t.Fatalf("code %q has %q, outside the alphabet", code, c)
+			}
+			counts[c]++
+		}
+	}
+	// Each letter is expected about 968 times; this only catches a badly
+	// skewed draw.
+	want := n * codeLength / len(codeAlphabet)
+	for _, c := range codeAlphabet {
+		if counts[c] < want*4/5 || counts[c] > want*6/5 {
+			t.Errorf("letter %c drawn %d times, want about %d", c, counts[c], want)
+		}
+	}
+}
+
+func TestRoomPassword(t *testing.T) {
+	var m roomMeta
+	m.setPassword("")
+	if m.hasPassword() {
+		t.Fatal("an empty password protected the room")
+	}
+	m.setPassword("hunter2")
+	if !m.hasPassword() {
+		t.Fatal("password not set")
+	}
+	for pw, want := range map[string]bool{"hunter2": true, "hunter3": false, "": false, "Hunter2": false} {
+		if got := m.checkPassword(pw); got != want {
+			t.Errorf("checkPassword(%q) = %v, want %v", pw, got, want)
+		}
+	}
+
+	var other roomMeta
+	other.setPassword("hunter2")
+	if string(other.PasswordHash) == string(m.PasswordHash) {
+		t.Error("two rooms with the same password share a hash")
+	}
+}
+
+func TestWhoOtherRoom(t *testing.T) {
+	private, open, other := newLobbyRoom(t, "hunter2"), newLobbyRoom(t, ""), newLobbyRoom(t, "")
+	member, outsider, neighbour := newTestConn(t), newTestConn(t), newTestConn(t)
+	seat(t, member, private, "m", "hunter2")
+	seat(t, outsider, open, "o", "")
+	seat(t, neighbour, other, "n", "")
+
+	tests := []struct {
+		name string
+		c    *Client
+		room string
+		want errcode.Code // "" if the roster is sent
+		ids  string
+	}{
+		{"own room", member, "", "", "m"},
+		{"own room by code", member, private, "", "m"},
+		{"open room", outsider, other, "", "n"},
+		{"password room", outsider, private, errcode.Unauthorized, ""},
+		{"no such room", outsider, "NOSUCH", errcode.NotFound, ""},
+	}
+	for _, tt := range tests {
+		received(tt.c)
+		_, err := dispatch(tt.c, &protocol.Who{Room: tt.room})
+		if errCode(err) != tt.want {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+		var p protocol.Players
+		got := receivedType(tt.c, "players", &p)
+		if got != (tt.want == "") {
+			t.Errorf("%s: roster sent = %v", tt.name, got)
+		}
+		var ids []string
+		for _, pl := range p.List {
+			ids = append(ids, pl.ID)
+		}
+		if strings.Join(ids, ",") != tt.ids {
+			t.Errorf("%s: roster %v, want %s", tt.name, ids, tt.ids)
+		}
+	}
+}
+
+func TestExpireRooms(t *testing.T) {
+	old := roomIdle
+	t.Cleanup(func() { roomIdle = old })
+
+	roomIdle = 0
+	expireRooms(context.Background()) // returns at once: rooms never expire
+
+	roomIdle = time.Millisecond // below the ticker's floor
+	room := newLobbyRoom(t, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		expireRooms(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done // before roomIdle is put back
+	}()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := getRoomMeta(room); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("empty room not expired")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"monopoly/config"
+	"monopoly/protocol"
+)
+
+/* ===== Logging ===== */
+
+// Attribute keys shared by every log line.
+const (
+	logRoom    = "room"
+	logPlayer  = "player"
+	logCmd     = "cmd"
+	logVersion = "version"
+	logPublic  = "public"
+)
+
+// public marks a record as meant for players too: besides the server log it
+// is broadcast as a serverLog message to its room, or to every room when it
+// has none. Never log a public record while holding mu.
+var public = slog.Bool(logPublic, true)
+
+// setupLogging installs the default logger. The standard log package is
+// routed through it as well.
+func setupLogging(c config.Config) {
+	opts := &slog.HandlerOptions{Level: c.Level()}
+	var h slog.Handler
+	if c.LogFormat == "json" {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(&serverLogHandler{next: h}))
+}
+
+func roomLog(room string) *slog.Logger {
+	return slog.With(logRoom, room)
+}
+
+func clientLog(c *Client) *slog.Logger {
+	return slog.With(logRoom, c.Room, logPlayer, c.ID)
+}
+
+// serverLogHandler feeds the serverLog broadcast from public records and
+// passes everything else, minus the public marker, to next.
+type serverLogHandler struct {
+	next    slog.Handler
+	room    string // set by With(logRoom, ...)
+	grouped bool   // attrs added after WithGroup belong to the group
+}
+
+// Enabled lets public records through whatever the level, so players see
+// them even when the server log is quieter.
+func (h *serverLogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= slog.LevelInfo || h.next.Enabled(ctx, l)
+}
+
+func (h *serverLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	room, isPublic := h.room, false
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		switch {
+		case a.Key == logPublic:
+			isPublic = a.Value.Bool()
+			return true
+		case a.Key == logRoom && !h.grouped:
+			room = a.Value.String()
+		}
+		out.AddAttrs(a)
+		return true
+	})
+
+	if isPublic {
+		broadcastLog(room, r.Message)
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, out)
+}
+
+func (h *serverLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := *h
+	cp.next = h.next.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == logRoom {
+				cp.room = a.Value.String()
+			}
+		}
+	}
+	return &cp
+}
+
+func (h *serverLogHandler) WithGroup(name string) slog.Handler {
+	cp := *h
+	cp.next = h.next.WithGroup(name)
+	cp.grouped = true
+	return &cp
+}
+
+// broadcastLog sends text as a serverLog message to room, or to every open
+// room when room is "".
+func broadcastLog(room, text string) {
+	msg := &protocol.ServerLog{Text: text}
+	if room != "" {
+		broadcast(room, msg)
+		return
+	}
+	for _, room := range openRooms() {
+		broadcast(room, msg)
+	}
+}
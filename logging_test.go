@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+// serverLogs returns the serverLog texts queued for c.
+func serverLogs(c *Client) []string {
+	var out []string
+	for _, b := range received(c) {
+		var m protocol.ServerLog
+		if json.Unmarshal(b, &m) == nil && m.Type == "serverLog" {
+			out = append(out, m.Text)
+		}
+	}
+	return out
+}
+
+func TestServerLogHandler(t *testing.T) {
+	alice := seatTestClients(t, "LOG001", game.Player{ID: "a", Name: "Alice"})[0]
+	bob := seatTestClients(t, "LOG002", game.Player{ID: "b", Name: "Bob"})[0]
+	var buf bytes.Buffer
+	log := slog.New(&serverLogHandler{next: slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})})
+
+	tests := []struct {
+		name       string
+		log        func()
+		alice, bob bool // who gets a serverLog
+		written    bool // whether the server log has the line
+	}{
+		{"public to a room", func() { log.With(logRoom, "LOG001").Info("hello", public) }, true, false, false},
+		{"room as a record attr", func() { log.Info("hello", logRoom, "LOG002", public) }, false, true, false},
+		{"public without a room", func() { log.Info("hello", public) }, true, true, false},
+		{"room inside a group", func() { log.WithGroup("req").With(logRoom, "LOG001").Info("hello", public) }, true, true, false},
+		{"private", func() { log.With(logRoom, "LOG001").Error("hello") }, false, false, true},
+		{"public and loud", func() { log.Warn("hello", logRoom, "LOG001", public) }, true, false, true},
+		{"below both levels", func() { log.Debug("hello", logRoom, "LOG001", public) }, false, false, false},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		tt.log()
+		if got := len(serverLogs(alice)) == 1; got != tt.alice {
+			t.Errorf("%s: Alice got a serverLog = %v, want %v", tt.name, got, tt.alice)
+		}
+		if got := len(serverLogs(bob)) == 1; got != tt.bob {
+			t.Errorf("%s: Bob got a serverLog = %v, want %v", tt.name, got, tt.bob)
+		}
+		if got := buf.Len() > 0; got != tt.written {
+			t.Errorf("%s: written = %v, want %v", tt.name, got, tt.written)
+		}
+		if strings.Contains(buf.String(), logPublic) {
+			t.Errorf("%s: the public marker reached the server log: %s", tt.name, buf.String())
+		}
+	}
+
+	buf.Reset()
+	log.With(logRoom, "LOG001", logPlayer, "a").Warn("moved", logCmd, "roll", public)
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("server log line %q: %v", buf.String(), err)
+	}
+	if line[logRoom] != "LOG001" || line[logPlayer] != "a" || line[logCmd] != "roll" || line["msg"] != "moved" {
+		t.Errorf("server log line = %v", line)
+	}
+}
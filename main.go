@@ -1,85 +1,251 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"math/rand"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
-	"sort"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"monopoly/config"
+	"monopoly/dice"
+	"monopoly/errcode"
+	"monopoly/game"
+	"monopoly/protocol"
+	"monopoly/ratelimit"
+	"monopoly/session"
 )
 
 // broadcast sends the same message to every client in the room.
-func broadcast(room string, msg any) {
-	b, _ := json.Marshal(msg)
+func broadcast(room string, msg protocol.Message) {
+	b, err := protocol.Marshal(msg)
+	if err != nil {
+		slog.Error("broadcast", logRoom, room, "err", err)
+		return
+	}
+	broadcastRaw(room, protocol.Type(msg), b)
+}
 
+// broadcastRaw is broadcast for an already encoded message of type typ.
+// Replays use it to re-send recorded messages as they were.
+func broadcastRaw(room, typ string, b []byte) {
+	start := time.Now()
 	mu.Lock()
 	set := rooms[room]
 	clients := make([]*Client, 0, len(set))
 	for cl := range set {
 		clients = append(clients, cl)
 	}
+	g, events := games[room], eventLogs[room]
 	mu.Unlock()
 
+	if g != nil && events != nil {
+		events.append(g.Version(), typ, b)
+	}
+
+	sent := 0
+	for _, cl := range clients {
+		if !cl.stream {
+			cl.writeRaw(b)
+			sent++
+		}
+	}
+	broadcastTime.Observe(time.Since(start).Seconds())
+	if sent > 0 {
+		messagesOut.Add(typ, uint64(sent))
+	}
+}
+
+// fanout sends msg to the connections in room that match accepts (all of
+// them when nil), SSE streams included. Unlike broadcast it leaves no trace
+// in the room's event log: use it for chatter that is not part of the game.
+func fanout(room string, msg protocol.Message, accepts func(*Client) bool) {
+	b, err := protocol.Marshal(msg)
+	if err != nil {
+		slog.Error("fanout", logRoom, room, "err", err)
+		return
+	}
+	mu.Lock()
+	var clients []*Client
+	for cl := range rooms[room] {
+		if accepts == nil || accepts(cl) {
+			clients = append(clients, cl)
+		}
+	}
+	mu.Unlock()
 	for _, cl := range clients {
 		cl.writeRaw(b)
 	}
+	if len(clients) > 0 {
+		messagesOut.Add(protocol.Type(msg), uint64(len(clients)))
+	}
 }
 
 /* ===== Models ===== */
 
-type Player struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
-
 type Client struct {
 	ID, Name, Room string
-	Conn           *websocket.Conn
-	SendMu         sync.Mutex // serialize writes
-}
-
-type inbound struct {
-	Type     string `json:"type"`
-	PlayerID string `json:"playerId"`
-	Name     string `json:"name"`
-	Room     string `json:"room"`
+	IP             string            // remote address the socket came from
+	Conn           *websocket.Conn   // nil for SSE streams
+	Limit          *ratelimit.Bucket // inbound messages on this connection
+
+	// stream marks an SSE connection. It reads broadcasts from the room's
+	// event log, so they carry event IDs; only direct sends use out.
+	stream bool
+
+	// spectator marks a connection watching a replay: it is never seated.
+	spectator bool
+
+	// Outbound messages are queued on out and written by writePump, so a
+	// slow reader never blocks broadcasts to the rest of the room.
+	out       chan []byte
+	done      chan struct{} // closed to stop writePump
+	pumpDone  chan struct{} // closed when writePump has exited
+	closeOnce sync.Once
+	closeMsg  []byte // close frame writePump sends on the way out
 }
 
 type rollReq struct {
 	PlayerID string `json:"playerId"`
 	Room     string `json:"room"`
-	Name     string `json:"name"` // optional (used for logging)
+	Name     string `json:"name"`    // optional (used for logging)
+	Entropy  string `json:"entropy"` // optional client entropy for fair dice
 }
 
 /* ===== Globals ===== */
 
 var (
+	// cfg is the effective configuration; applyConfig copies it into the
+	// settings below.
+	cfg = config.Defaults()
+
+	// origins is the browser origin allowlist shared by the websocket
+	// upgrader and every HTTP endpoint.
+	origins originPolicy
+
 	upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+		CheckOrigin: func(r *http.Request) bool { return origins.allows(r) },
 	}
 
-	mu    sync.Mutex
-	rooms = make(map[string]map[*Client]struct{}) // room -> clients
-	turn  = make(map[string]*Client)              // room -> current player
+	mu        sync.Mutex
+	rooms     = make(map[string]map[*Client]struct{}) // room -> clients
+	games     = make(map[string]*game.Room)           // room -> server-authoritative game state
+	eventLogs = make(map[string]*eventLog)            // room -> recent broadcasts
+	reveals   = make(map[string]dice.Record)          // room -> latest fair dice reveal
 
-	// positions[room][playerID] = tileIndex (0..39). We keep server-authoritative positions.
-	positions = make(map[string]map[string]int)
+	// fairDice switches new rooms to commit-reveal dice.
+	fairDice = false
+
+	// newDice creates the dice for a newly opened room. Swap it out to
+	// script or replay games.
+	newDice = func() dice.Dice {
+		if fairDice {
+			return dice.NewFair()
+		}
+		return dice.NewRandom()
+	}
+
+	// sessions signs the tokens handed out on resume. Configure a session
+	// secret to keep tokens valid across restarts.
+	sessions *session.Signer
 
 	maxPlayers = 10
+
+	// Per-client outbound queue length and how long a single write may take.
+	// A client whose queue overflows is disconnected.
+	sendBuffer = 256
+	writeWait  = 10 * time.Second
+
+	// Keepalive: the server pings every pingPeriod and drops connections
+	// that have sent nothing (not even a pong) for pongWait.
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10 // recomputed by applyConfig
+	closeGrace = 2 * time.Second
+
+	// Throttles: every inbound WS message per connection and per IP (HTTP
+	// calls count against the same IP bucket), rolls per playerId, and chat
+	// lines and reactions per seat.
+	connRate      = 10.0 // messages/sec per connection
+	connBurst     = 20
+	ipLimit       = ratelimit.New(20, 40)
+	playerLimit   = ratelimit.New(1, 3)
+	chatLimit     = ratelimit.New(0.5, 5)
+	reactionLimit = ratelimit.New(1, 3)
 )
 
 /* ===== CORS ===== */
 
+// originPolicy decides which browser origins may call the server. Requests
+// without an Origin header (curl, load.go) and same-origin requests are
+// always allowed. Entries are exact origins such as "https://game.example"
+// or "http://localhost:*" to allow any port on a dev host; "*" allows all.
+type originPolicy struct {
+	any     bool
+	exact   map[string]bool
+	anyPort map[string]bool // "scheme://host" -> true
+}
+
+// parseOrigins reads a comma-separated allowlist.
+func parseOrigins(list string) originPolicy {
+	p := originPolicy{exact: map[string]bool{}, anyPort: map[string]bool{}}
+	for _, o := range strings.Split(list, ",") {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		switch {
+		case o == "":
+		case o == "*":
+			p.any = true
+		case strings.HasSuffix(o, ":*"):
+			p.anyPort[strings.TrimSuffix(o, ":*")] = true
+		default:
+			p.exact[o] = true
+		}
+	}
+	return p
+}
+
+func (p originPolicy) allows(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.any || p.exact[origin] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true // same origin
+	}
+	return p.anyPort[u.Scheme+"://"+u.Hostname()]
+}
+
+// withCORS rejects requests from origins outside the allowlist and answers
+// preflights for the rest.
 func withCORS(h http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Vary", "Origin")
+		if !origins.allows(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, X-Room-Password")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Retry-After")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -92,130 +258,305 @@ func withCORS(h http.HandlerFunc) http.HandlerFunc {
 /* ===== Main ===== */
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	c, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("config", "err", err)
+		os.Exit(1)
+	}
+	applyConfig(c)
+	effective, _ := json.Marshal(cfg.Redacted())
+	slog.Info("config loaded", "config", json.RawMessage(effective))
+
+	mux := newMux()
+
+	slog.Info("listening", "addr", cfg.Addr, "tls", cfg.TLS())
+	if !origins.any && len(origins.exact)+len(origins.anyPort) == 0 {
+		slog.Info("only same-origin browsers allowed; set -allowed-origins (e.g. http://localhost:*) for dev servers")
+	}
+	if err := loadCheckpoint(checkpointPath); err != nil {
+		slog.Error("restore", "path", checkpointPath, "err", err)
+	}
+	slog.Info("Server started; waiting for players...", public)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go expireRooms(ctx)
+	runWebhooks(ctx)
+
+	srv := newServer(mux)
+	redirect := newRedirectServer()
+	if redirect != nil {
+		slog.Info("redirecting to https", "addr", redirect.Addr)
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("redirect listener", "err", err)
+				os.Exit(1)
+			}
+		}()
+	}
+	go func() {
+		var err error
+		if cfg.TLS() {
+			err = srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("listener", "err", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	if redirect != nil {
+		_ = redirect.Close()
+	}
+	shutdown(srv)
+}
 
-	http.HandleFunc("/ws", withCORS(wsHandler))
-	http.HandleFunc("/roll", withCORS(rollHTTP))
-	http.HandleFunc("/debug/players", withCORS(debugPlayersHTTP))
+// newMux routes every endpoint. /debug is only registered with -debug.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", withCORS(wsHandler))
+	mux.HandleFunc("/roll", withCORS(rollHTTP))
+	mux.HandleFunc("/verify", withCORS(verifyHTTP))
+	mux.HandleFunc("/rooms/{room}/fair", withCORS(roomFairHTTP))
+	mux.HandleFunc("/protocol/schema.json", withCORS(schemaHTTP))
+	mux.HandleFunc("/config", withCORS(configHTTP))
+	mux.HandleFunc("/rooms", withCORS(roomsHTTP))
+	mux.HandleFunc("/rooms/{room}/state", withCORS(roomStateHTTP))
+	mux.HandleFunc("/rooms/{room}/players/{pid}", withCORS(roomPlayerHTTP))
+	mux.HandleFunc("/rooms/{room}/board", withCORS(roomBoardHTTP))
+	mux.HandleFunc("/rooms/{room}/events", withCORS(roomEventsHTTP))
+	mux.HandleFunc("/rooms/{room}/stream", withCORS(roomStreamHTTP))
+	mux.HandleFunc("/rooms/{room}/commands", withCORS(roomCommandHTTP))
+	mux.HandleFunc("/rooms/{room}/recording", withCORS(roomRecordingHTTP))
+	mux.HandleFunc("/rooms/{room}/replay", withCORS(roomReplayHTTP))
+	mux.HandleFunc("/rooms/{room}/replay/speed", withCORS(replaySpeedHTTP))
+	mux.Handle("/metrics", registry)
+
+	mux.HandleFunc("GET /admin/rooms", withAdmin(adminRoomsHTTP))
+	mux.HandleFunc("GET /admin/rooms/{room}", withAdmin(adminRoomHTTP))
+	mux.HandleFunc("POST /admin/rooms/{room}/kick", withAdmin(adminKickHTTP))
+	mux.HandleFunc("POST /admin/rooms/{room}/pass", withAdmin(adminPassHTTP))
+	mux.HandleFunc("POST /admin/rooms/{room}/pause", withAdmin(adminPauseHTTP(true)))
+	mux.HandleFunc("POST /admin/rooms/{room}/resume", withAdmin(adminPauseHTTP(false)))
+	mux.HandleFunc("POST /admin/rooms/{room}/close", withAdmin(adminCloseHTTP))
+	mux.HandleFunc("POST /admin/rooms/{room}/mute", withAdmin(adminMuteHTTP))
+	mux.HandleFunc("POST /admin/broadcast", withAdmin(adminBroadcastHTTP))
+	mux.HandleFunc("GET /admin/recordings", withAdmin(adminRecordingsHTTP))
+	mux.HandleFunc("POST /admin/recordings", withAdmin(adminImportRecordingHTTP))
+	mux.HandleFunc("GET /admin/recordings/{id}", withAdmin(adminRecordingHTTP))
+	mux.HandleFunc("POST /admin/recordings/{id}/replay", withAdmin(adminReplayHTTP))
+	mux.HandleFunc("GET /admin/webhooks", withAdmin(adminHooksHTTP))
+	mux.HandleFunc("POST /admin/webhooks", withAdmin(adminAddHookHTTP))
+	mux.HandleFunc("GET /admin/webhooks/{id}", withAdmin(adminHookHTTP))
+	mux.HandleFunc("DELETE /admin/webhooks/{id}", withAdmin(adminDeleteHookHTTP))
+	mux.HandleFunc("POST /admin/webhooks/{id}/test", withAdmin(adminTestHookHTTP))
+	if cfg.Debug {
+		registerDebug(mux)
+	}
 
 	// Serve HTML
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case "/", "/index.html":
-			http.ServeFile(w, r, "index.html")
+			serveStatic(w, r, "index.html")
 			return
 		case "/game.html":
-			http.ServeFile(w, r, "game.html")
+			serveStatic(w, r, "game.html")
 			return
 		default:
 			http.NotFound(w, r)
 			return
 		}
 	})
+	return mux
+}
 
-	addr := ":8081"
-	log.Printf("listening on %s (ws: /ws, roll: /roll)", addr)
-	go broadcastServerLog("Server started; waiting for players...")
+// applyConfig installs c as the effective configuration.
+func applyConfig(c config.Config) {
+	cfg = c
+	setupLogging(c)
+	origins = parseOrigins(c.AllowedOrigins)
+	sessions = session.NewSigner([]byte(c.SessionSecret), 24*time.Hour)
+	maxPlayers = c.Rules.MaxPlayers
+	fairDice = c.Rules.FairDice
+	writeWait = time.Duration(c.WriteTimeout)
+	pongWait = time.Duration(c.PongTimeout)
+	pingPeriod = pongWait * 9 / 10
+	shutdownTimeout = time.Duration(c.ShutdownTimeout)
+	roomIdle = time.Duration(c.RoomIdleTimeout)
+	restartETA = time.Duration(c.RestartETA)
+	checkpointPath = c.StatePath
+	chatFilter = blockedWords(c.ChatBlockedWords)
+}
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		log.Fatal(err)
-	}
+/* ===== REST: /config ===== */
+
+// configHTTP exposes the settings the frontend needs. Nothing secret or
+// host-specific goes here.
+func configHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"protocolVersion": protocol.Version,
+		"wsPath":          "/ws",
+		"rollPath":        "/roll",
+		"tls":             cfg.TLS(),
+		"pingIntervalMs":  pingPeriod.Milliseconds(),
+		"rules":           cfg.Rules,
+		"emotes":          protocol.Emotes,
+	})
 }
 
 /* ===== REST: /roll ===== */
 
 func rollHTTP(w http.ResponseWriter, r *http.Request) {
-	// --- CORS FIX FOR BROWSER ---
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-	// Browser preflight request
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
+	// Only POST allowed (CORS and preflight are handled by withCORS)
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errorMsg("", errMethodNotAllowed))
 		return
 	}
 
-	// Only POST allowed
-	if r.Method != http.MethodPost {
-		w.Header().Set("Allow", http.MethodPost)
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	if ok, retry := allow(ipLimit, "ip", remoteIP(r)); !ok {
+		writeError(w, rateLimitedMsg("", retry))
 		return
 	}
 
 	var req rollReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("decode error: %v", err)
-		http.Error(w, "bad json", http.StatusBadRequest)
+		slog.Debug("bad roll request", "err", err)
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "bad json")))
 		return
 	}
 	if req.Room == "" || req.PlayerID == "" {
-		http.Error(w, "missing room or playerId", http.StatusBadRequest)
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "missing room or playerId")))
+		return
+	}
+
+	// The token must be the one issued to this seat on resume
+	if err := verifySession(bearerToken(r), req.Room, req.PlayerID); err != nil {
+		writeError(w, errorMsg("", err))
 		return
 	}
 
 	c := getClientByID(req.Room, req.PlayerID)
 	if c == nil {
-		http.Error(w, "player not connected in room", http.StatusNotFound)
+		writeError(w, errorMsg("", errcode.New(errcode.NotSeated, "player not connected in room")))
 		return
 	}
 
-	log.Println("Rolling Dice...", c.Name, short(c.ID), "in room", req.Room)
-
-	// Turn check
-	holder := getTurnHolder(req.Room)
-	if holder == nil || holder != c {
-		http.Error(w, "not your turn", http.StatusForbidden)
-		c.writeJSON(map[string]any{"type": "event", "text": "Not your turn."})
+	if ok, retry := allow(playerLimit, "player", req.PlayerID); !ok {
+		writeError(w, rateLimitedMsg("", retry))
 		return
 	}
 
-	// Authoritative dice & move
-	d1, d2 := 1+rand.Intn(6), 1+rand.Intn(6)
-	total := d1 + d2
-
-	from := getPos(req.Room, c.ID) // defaults to 0 if absent
-	to := (from + total) % 40
-	setPos(req.Room, c.ID, to)
-
-	// Broadcast event + move (with dice for the roller)
-	broadcast(req.Room, map[string]any{
-		"type": "event",
-		"text": fmt.Sprintf("%s rolled %d (%d + %d)", c.Name, total, d1, d2),
-	})
-	broadcast(req.Room, map[string]any{
-		"type":     "move",
-		"playerId": c.ID,
-		"from":     from,
-		"to":       to,
-		"dice":     []int{d1, d2},
-	})
-
-	// Pass turn
-	passTurn(req.Room, c)
+	res, err := roll(req.Room, c, req.Entropy)
+	if err != nil {
+		writeError(w, errorMsg("", err))
+		return
+	}
 
 	// Response for the caller
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"ok":    true,
-		"dice":  []int{d1, d2},
-		"total": total,
+		"dice":  res.Dice,
+		"total": res.Total,
 	})
 }
 
+/* ===== REST: /verify ===== */
+
+// verifyHTTP recomputes every roll of a revealed fair dice record.
+func verifyHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errorMsg("", errMethodNotAllowed))
+		return
+	}
+
+	var rec dice.Record
+	if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "bad json")))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := dice.Verify(rec); err != nil {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "rolls": len(rec.Rolls)})
+}
+
+// roomFairHTTP serves a fair dice room's current commitment and its latest
+// reveal, which outlives the game so players can POST it to /verify.
+func roomFairHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readableRoom(w, r); !ok {
+		return
+	}
+	room := r.PathValue("room")
+	mu.Lock()
+	rec, revealed := reveals[room]
+	mu.Unlock()
+	out := map[string]any{}
+	if f, ok := getDice(room).(*dice.Fair); ok {
+		out["commitment"] = f.Commitment()
+	}
+	if revealed {
+		out["reveal"] = rec
+	}
+	if len(out) == 0 {
+		writeError(w, errorMsg("", errcode.New(errcode.NotFound, "This room has no fair dice.")))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+/* ===== REST: /protocol/schema.json ===== */
+
+func schemaHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := protocol.Schema()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(b)
+}
+
 /* ===== WS: /ws ===== */
 
 func wsHandler(w http.ResponseWriter, r *http.Request) {
 	cn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("upgrade:", err)
+		slog.Debug("websocket upgrade failed", "ip", remoteIP(r), "err", err)
 		return
 	}
-	client := &Client{Conn: cn}
+	client := newClient(cn, remoteIP(r))
+	go client.writePump()
+	trackConn(client)
+
+	// Half-open connections stop answering pings; the read deadline then
+	// fails ReadMessage and the normal onClose path frees the seat.
+	_ = cn.SetReadDeadline(time.Now().Add(pongWait))
+	cn.SetPongHandler(func(string) error {
+		return cn.SetReadDeadline(time.Now().Add(pongWait))
+	})
 
 	defer func() {
 		onClose(client)
+		client.close(websocket.CloseNormalClosure, "")
+		<-client.pumpDone
 		_ = cn.Close()
+		untrackConn(client)
 	}()
 
 	for {
@@ -223,88 +564,192 @@ func wsHandler(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			return
 		}
+		_ = cn.SetReadDeadline(time.Now().Add(pongWait))
 
-		var in inbound
-		_ = json.Unmarshal(data, &in)
+		msg, decErr := protocol.Decode(data)
+		if decErr != nil {
+			messagesIn.Inc("invalid")
+		} else {
+			messagesIn.Inc(protocol.Type(msg))
+		}
 
-		switch in.Type {
-		case "resume":
-			client.ID = in.PlayerID
-			client.Name = in.Name
-			if in.Room != "" {
-				client.Room = in.Room
-			} else if client.Room == "" {
-				client.Room = "default"
-			}
+		if ok, retry := allowInbound(client, msg); !ok {
+			client.send(rateLimitedMsg(protocol.PeekID(data), retry))
+			continue
+		}
+		if decErr != nil {
+			client.send(errorMsg(protocol.PeekID(data), errcode.New(errcode.BadMessage, decErr.Error())))
+			continue
+		}
 
-			if !addToRoom(client.Room, client) {
-				client.writeJSON(map[string]any{"type": "event", "text": "Room is full (10 players max)."})
-				return
-			}
-			broadcastServerLogTo(client.Room, fmt.Sprintf("%s connected (%s)", client.Name, short(client.ID)))
+		closeConn, err := dispatch(client, msg)
+		logCommand(client, msg, err)
+		if ref := protocol.RequestID(msg); err != nil {
+			client.send(errorMsg(ref, err))
+		} else if ref != "" {
+			client.send(&protocol.Ack{Ref: ref})
+		}
+		if closeConn {
+			return
+		}
+	}
+}
+
+// dispatch handles one decoded inbound message. The error, if any, is sent
+// back to the client referencing the message's id; closeConn ends the
+// connection afterwards. Every command that acts on the sender's seat must
+// carry the session token issued on resume.
+func dispatch(client *Client, msg protocol.Message) (closeConn bool, err error) {
+	if client.spectator {
+		if handled, err := spectate(client, msg); handled {
+			return false, err
+		}
+	}
+	if tok := protocol.SessionToken(msg); tok != nil {
+		if err := verifySession(*tok, client.Room, client.ID); err != nil {
+			return false, err
+		}
+	}
+	switch in := msg.(type) {
+	case *protocol.Resume:
+		if err := join(client, in); err != nil {
+			return false, err // the client may retry, e.g. with the password
+		}
 
-			// Ensure initial position at GO
-			ensurePos(client.Room, client.ID)
+	case *protocol.Who:
+		room := in.Room
+		if room == "" {
+			room = client.Room
+		}
+		if room != client.Room {
+			// Outsiders only see who is in rooms without a password.
+			meta, ok := getRoomMeta(room)
+			if !ok {
+				return false, errNoRoomCode
+			}
+			if meta.hasPassword() {
+				return false, errcode.New(errcode.Unauthorized, "Join the room to see who is in it.")
+			}
+		}
+		client.send(&protocol.Players{List: roster(room)})
 
-			// Broadcast roster + joined delta
-			broadcast(client.Room, map[string]any{"type": "players", "list": roster(client.Room)})
-			broadcast(client.Room, map[string]any{"type": "playerJoined", "player": Player{ID: client.ID, Name: client.Name}})
+	case *protocol.SubscribeLogs:
+		client.send(&protocol.ServerLog{Text: "Subscribed to server logs for room " + client.Room})
 
-			// Send a state snapshot so clients can render tokens (GO for new players)
-			broadcast(client.Room, map[string]any{"type": "state", "positions": snapshotPositions(client.Room)})
+	case *protocol.Sync:
+		g := getGame(client.Room)
+		if g == nil {
+			return false, game.ErrNotSeated
+		}
+		client.send(&protocol.Players{List: g.Players()})
+		client.send(snapshot(g))
+		client.send(chatHistory(client))
 
-			// Ensure someone has the turn
-			ensureTurnHolder(client.Room)
+	case *protocol.Chat:
+		if err := chat(client, in); err != nil {
+			return false, err
+		}
 
-		case "who":
-			room := in.Room
-			if room == "" {
-				room = client.Room
-			}
-			client.writeJSON(map[string]any{"type": "players", "list": roster(room)})
+	case *protocol.Mute:
+		if err := mute(client, in); err != nil {
+			return false, err
+		}
 
-		case "subscribeLogs":
-			client.writeJSON(map[string]any{"type": "serverLog", "text": "Subscribed to server logs for room " + client.Room})
+	case *protocol.React:
+		if err := react(client, in); err != nil {
+			return false, err
+		}
 
-		case "roll":
-			room := client.Room
-			if room == "" {
-				break
-			}
-			holder := getTurnHolder(room)
-			if holder == nil || holder != client {
-				client.writeJSON(map[string]any{"type": "event", "text": "Not your turn."})
-				break
-			}
-			// Server-authoritative dice + move
-			d1, d2 := 1+rand.Intn(6), 1+rand.Intn(6)
-			total := d1 + d2
-
-			from := getPos(room, client.ID)
-			to := (from + total) % 40
-			setPos(room, client.ID, to)
-
-			broadcast(room, map[string]any{
-				"type": "event",
-				"text": fmt.Sprintf("%s rolled %d (%d + %d)", client.Name, total, d1, d2),
-			})
-			broadcast(room, map[string]any{
-				"type":     "move",
-				"playerId": client.ID,
-				"from":     from,
-				"to":       to,
-				"dice":     []int{d1, d2},
-			})
-
-			passTurn(room, client)
-
-		case "ping":
-			// ignore
-
-		case "leave":
-			return
+	case *protocol.Roll:
+		if _, err := roll(client.Room, client, in.Entropy); err != nil {
+			return false, err
 		}
+
+	case *protocol.Reveal:
+		revealDice(client.Room)
+
+	case *protocol.Ping:
+		client.send(&protocol.Pong{T: in.T, ServerTime: time.Now().UnixMilli()})
+
+	case *protocol.Leave:
+		return true, nil
 	}
+	return false, nil
+}
+
+// join seats client as in describes: the resume path shared by WebSocket
+// and SSE connections.
+func join(client *Client, in *protocol.Resume) error {
+	if inRoom(client) {
+		return errAlreadyJoined // the old seat would be left without a connection
+	}
+	meta, ok := getRoomMeta(in.Room)
+	if !ok {
+		return errNoRoomCode
+	}
+	if meta.Replay {
+		return watchReplay(client, in)
+	}
+	if meta.Kicked[in.PlayerID] {
+		return errKicked
+	}
+	client.ID = in.PlayerID
+	client.Name = in.Name
+	client.Room = in.Room
+
+	// A seat that has been taken before can only be resumed with its token;
+	// anyone else needs the room password, if it has one
+	tokenErr := verifySession(in.Token, client.Room, client.ID)
+	if g := getGame(client.Room); g != nil && g.Known(client.ID) && tokenErr != nil {
+		return tokenErr
+	}
+	if meta.hasPassword() && tokenErr != nil && !meta.checkPassword(in.Password) {
+		return errcode.New(errcode.Unauthorized, "Wrong room password.")
+	}
+
+	if !addToRoom(client.Room, client) {
+		return errcode.New(errcode.RoomFull, fmt.Sprintf("Room is full (%d players max).", maxPlayers))
+	}
+	clientLog(client).Info(fmt.Sprintf("%s connected (%s)", client.Name, short(client.ID)), public)
+	client.send(&protocol.Session{Token: sessions.Issue(client.Room, client.ID)})
+
+	// Take a seat (GO for new players); the first player gets the turn
+	g := getGame(client.Room)
+	gotTurn := g.Join(game.Player{ID: client.ID, Name: client.Name})
+
+	// Broadcast roster + joined delta
+	player := game.Player{ID: client.ID, Name: client.Name}
+	broadcast(client.Room, &protocol.Players{List: g.Players()})
+	broadcast(client.Room, &protocol.PlayerJoined{Player: player})
+	if gotTurn && len(g.Players()) == 1 {
+		emitHook(hookGameStarted, client.Room, map[string]any{"player": player})
+	}
+	emitHook(hookPlayerJoined, client.Room, map[string]any{"player": player})
+
+	// Send a state snapshot so clients can render tokens (GO for new players)
+	broadcast(client.Room, snapshot(g))
+
+	if gotTurn {
+		go notifyTurn(client.Room)
+	}
+	return nil
+}
+
+// logCommand records one handled inbound message with the room version it
+// left behind. Rejections are logged at info, everything else at debug.
+func logCommand(c *Client, msg protocol.Message, err error) {
+	if err == nil && !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	l := clientLog(c).With(logCmd, protocol.Type(msg))
+	if g := getGame(c.Room); g != nil {
+		l = l.With(logVersion, g.Version())
+	}
+	if err != nil {
+		l.Info("command rejected", "code", errcode.From(err).Code, "err", err)
+		return
+	}
+	l.Debug("command handled")
 }
 
 func onClose(c *Client) {
@@ -312,21 +757,140 @@ func onClose(c *Client) {
 		return
 	}
 	removed := removeFromRoom(c.Room, c)
+	if removed && c.spectator {
+		clientLog(c).Info("stopped watching replay")
+		return
+	}
 	if removed {
-		broadcastServerLogTo(c.Room, fmt.Sprintf("%s disconnected (%s)", c.Name, short(c.ID)))
+		clientLog(c).Info(fmt.Sprintf("%s disconnected (%s)", c.Name, short(c.ID)), public)
+
+		// Give up the seat unless the player is still connected elsewhere
+		turnChanged, hostChanged := false, false
+		g := getGame(c.Room)
+		if g != nil && getClientByID(c.Room, c.ID) == nil {
+			wasHost := g.Host() == c.ID
+			_, turnChanged = g.Leave(c.ID)
+			hostChanged = wasHost && g.Host() != ""
+		}
 
 		// Update roster + left delta
-		broadcast(c.Room, map[string]any{"type": "players", "list": roster(c.Room)})
-		broadcast(c.Room, map[string]any{"type": "playerLeft", "player": Player{ID: c.ID, Name: c.Name}})
+		broadcast(c.Room, &protocol.Players{List: roster(c.Room)})
+		broadcast(c.Room, &protocol.PlayerLeft{Player: game.Player{ID: c.ID, Name: c.Name}})
+		if hostChanged {
+			broadcast(c.Room, snapshot(g)) // carries the new host
+		}
+		if !isDraining() { // the game carries on after the restart
+			emitHook(hookPlayerLeft, c.Room, map[string]any{"player": game.Player{ID: c.ID, Name: c.Name}})
+			if getGame(c.Room) == nil {
+				emitHook(hookGameEnded, c.Room, nil)
+			}
+		}
 
-		// If turn holder left, advance
-		holder := getTurnHolder(c.Room)
-		if holder == nil || holder == c {
-			passTurn(c.Room, c)
+		// If turn holder left, announce the next one
+		if turnChanged {
+			go notifyTurn(c.Room)
 		}
 	}
 }
 
+/* ===== Rate limiting ===== */
+
+// allow spends a token from key's bucket in l, counting the drop under scope.
+func allow(l *ratelimit.Limiter, scope, key string) (bool, time.Duration) {
+	ok, retry := l.Allow(key)
+	if !ok {
+		rateLimitDrops.Add(scope, 1)
+	}
+	return ok, retry
+}
+
+// allowInbound applies the connection, IP and (for rolls) player throttles
+// to one inbound WS message.
+func allowInbound(c *Client, msg protocol.Message) (bool, time.Duration) {
+	if ok, retry := c.Limit.Allow(); !ok {
+		rateLimitDrops.Add("conn", 1)
+		return false, retry
+	}
+	if ok, retry := allow(ipLimit, "ip", c.IP); !ok {
+		return false, retry
+	}
+	if c.ID == "" {
+		return true, 0
+	}
+	switch msg.(type) {
+	case *protocol.Roll:
+		return allow(playerLimit, "player", c.ID)
+	case *protocol.Chat:
+		return allow(chatLimit, "chat", c.Room+"/"+c.ID)
+	case *protocol.React:
+		return allow(reactionLimit, "reaction", c.Room+"/"+c.ID)
+	}
+	return true, 0
+}
+
+func rateLimitedMsg(ref string, retry time.Duration) *protocol.Error {
+	rejected.Inc(string(errcode.RateLimited))
+	return &protocol.Error{
+		Ref:          ref,
+		Code:         errcode.RateLimited,
+		Message:      "Too many requests; slow down.",
+		RetryAfterMs: retry.Milliseconds(),
+	}
+}
+
+// remoteIP is the peer address of r without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+/* ===== Errors ===== */
+
+var (
+	errMethodNotAllowed = errcode.New(errcode.MethodNotAllowed, "method not allowed")
+	errNoRoom           = errcode.New(errcode.NotFound, "no such room")
+	errAlreadyJoined    = errcode.New(errcode.BadMessage, "This connection already holds a seat; open a new one to switch.")
+	errKicked           = errcode.New(errcode.Unauthorized, "You were removed from this room.")
+)
+
+// errorMsg converts err into the wire error replying to request ref.
+func errorMsg(ref string, err error) *protocol.Error {
+	e := errcode.From(err)
+	rejected.Inc(string(e.Code))
+	return &protocol.Error{Ref: ref, Code: e.Code, Message: e.Message}
+}
+
+// writeError answers an HTTP request with the same error shape WebSocket
+// clients get, using the status code the catalog assigns to its code.
+func writeError(w http.ResponseWriter, msg *protocol.Error) {
+	if msg.RetryAfterMs > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(msg.RetryAfterMs/1000+1))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(msg.Code.HTTPStatus())
+	b, _ := protocol.Marshal(msg)
+	_, _ = w.Write(b)
+}
+
+/* ===== Sessions ===== */
+
+// verifySession checks that token was issued for the seat.
+func verifySession(token, room, playerID string) error {
+	if err := sessions.Verify(token, room, playerID); err != nil {
+		return errcode.New(errcode.Unauthorized, err.Error())
+	}
+	return nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	tok, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return tok
+}
+
 /* ===== Rooms / Roster ===== */
 
 func addToRoom(room string, c *Client) bool {
@@ -337,11 +901,19 @@ func addToRoom(room string, c *Client) bool {
 	if set == nil {
 		set = make(map[*Client]struct{})
 		rooms[room] = set
+		if games[room] == nil { // may already exist, restored from a checkpoint
+			games[room] = game.NewRoom(room, newDice())
+		}
+		if eventLogs[room] == nil {
+			eventLogs[room] = &eventLog{}
+		}
+		go announceSeed(room)
 	}
 	if len(set) >= maxPlayers {
 		return false
 	}
 	set[c] = struct{}{}
+	touchRoomLocked(room)
 	return true
 }
 
@@ -356,29 +928,77 @@ func removeFromRoom(room string, c *Client) bool {
 		return false
 	}
 	delete(set, c)
+	touchRoomLocked(room)
 	if len(set) == 0 {
 		delete(rooms, room)
-		delete(turn, room)
-		delete(positions, room)
+		if replays[room] != nil {
+			return true // the replay keeps running until the room expires
+		}
+		if c.spectator { // of a replay that was closed: nothing was played here
+			delete(games, room)
+			delete(eventLogs, room)
+			return true
+		}
+		if !draining {
+			gamesCompleted.Inc()
+		}
+		g := games[room]
+		var fair *dice.Record
+		if f, ok := g.Dice().(*dice.Fair); ok {
+			rec := f.Reveal()
+			logReveal(room, rec)
+			reveals[room] = rec // nobody is left to receive it; see roomFairHTTP
+			fair = &rec
+		}
+		if l := eventLogs[room]; l != nil && g != nil && !draining {
+			var name string
+			if m := lobby[room]; m != nil {
+				name = m.Name
+			}
+			if rec := newRecording(name, g, l, fair, time.Now()); rec.played() {
+				keepRecordingLocked(rec)
+			}
+		}
+		delete(games, room)
+		delete(eventLogs, room)
+		delete(chats, room)
 	}
 	return true
 }
 
-func roster(room string) []Player {
+// inRoom reports whether c currently holds a place in its room.
+func inRoom(c *Client) bool {
 	mu.Lock()
 	defer mu.Unlock()
-	out := make([]Player, 0, 8)
-	for cl := range rooms[room] {
-		out = append(out, Player{ID: cl.ID, Name: cl.Name})
+	_, ok := rooms[c.Room][c]
+	return ok
+}
+
+// openRooms lists the rooms with at least one connection.
+func openRooms() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]string, 0, len(rooms))
+	for room := range rooms {
+		list = append(list, room)
 	}
-	// Sort for stable order (by Name then ID)
-	sort.Slice(out, func(i, j int) bool {
-		if out[i].Name == out[j].Name {
-			return out[i].ID < out[j].ID
-		}
-		return out[i].Name < out[j].Name
-	})
-	return out
+	return list
+}
+
+// roster lists the seated players, sorted by Name then ID.
+func roster(room string) []game.Player {
+	g := getGame(room)
+	if g == nil {
+		return []game.Player{}
+	}
+	return g.Players()
+}
+
+// getGame returns the room's game state, or nil if the room is not open.
+func getGame(room string) *game.Room {
+	mu.Lock()
+	defer mu.Unlock()
+	return games[room]
 }
 
 func getClientByID(room, playerID string) *Client {
@@ -392,203 +1012,356 @@ func getClientByID(room, playerID string) *Client {
 	return nil
 }
 
-/* ===== Positions (server-authoritative) ===== */
+/* ===== Dice ===== */
 
-func ensurePos(room, playerID string) {
-	mu.Lock()
-	defer mu.Unlock()
-	if positions[room] == nil {
-		positions[room] = make(map[string]int)
-	}
-	if _, ok := positions[room][playerID]; !ok {
-		positions[room][playerID] = 0 // GO for brand new players
+func getDice(room string) dice.Dice {
+	if g := getGame(room); g != nil {
+		return g.Dice()
 	}
+	return nil
 }
 
-func getPos(room, playerID string) int {
-	mu.Lock()
-	defer mu.Unlock()
-	if positions[room] == nil {
-		return 0
+// announceSeed records the room's dice seed in the server log so the game
+// can be replayed exactly with dice.NewSeeded. The seed predicts every roll,
+// so it is never sent to the room; fair dice only publish the commitment
+// until revealDice.
+func announceSeed(room string) {
+	switch d := getDice(room).(type) {
+	case *dice.Fair:
+		c := d.Commitment()
+		roomLog(room).Info("Fair dice commitment: "+c, public)
+		broadcast(room, &protocol.FairCommit{Commitment: c})
+	case interface{ Seed() int64 }:
+		roomLog(room).Info("dice seeded", "seed", d.Seed())
 	}
-	return positions[room][playerID]
 }
 
-func setPos(room, playerID string, idx int) {
-	mu.Lock()
-	defer mu.Unlock()
-	if positions[room] == nil {
-		positions[room] = make(map[string]int)
+// revealDice publishes the room's fair dice seed and roll record, then
+// announces the commitment for the next seed.
+func revealDice(room string) {
+	f, ok := getDice(room).(*dice.Fair)
+	if !ok {
+		return
 	}
-	positions[room][playerID] = ((idx % 40) + 40) % 40
+	rec := f.Reveal()
+	logReveal(room, rec)
+	mu.Lock()
+	reveals[room] = rec
+	mu.Unlock()
+	broadcast(room, &protocol.FairReveal{Record: rec})
+	announceSeed(room)
 }
 
-func snapshotPositions(room string) map[string]int {
-	mu.Lock()
-	defer mu.Unlock()
-	cp := make(map[string]int, len(positions[room]))
-	for k, v := range positions[room] {
-		cp[k] = v
+func logReveal(room string, rec dice.Record) {
+	b, _ := json.Marshal(rec)
+	roomLog(room).Info("fair dice revealed", "record", json.RawMessage(b))
+}
+
+/* ===== Commands ===== */
+
+// roll is the single roll path shared by HTTP and WebSocket: the game layer
+// applies the rules, then the outcome is broadcast to the room.
+func roll(room string, c *Client, entropy string) (game.RollResult, error) {
+	g := getGame(room)
+	if g == nil {
+		return game.RollResult{}, game.ErrNotSeated
 	}
-	return cp
+	res, err := g.Roll(c.ID, entropy)
+	if err != nil {
+		return res, err
+	}
+	rolls.Inc()
+	clientLog(c).Info("rolled", logCmd, "roll", "dice", res.Dice, "from", res.From, "to", res.To, logVersion, res.Version)
+
+	// Broadcast event + move (with dice for the roller)
+	broadcast(room, &protocol.Event{
+		Text: fmt.Sprintf("%s rolled %d (%d + %d)", c.Name, res.Total, res.Dice[0], res.Dice[1]),
+	})
+	broadcast(room, &protocol.Move{
+		PlayerID: c.ID,
+		From:     res.From,
+		To:       res.To,
+		Dice:     res.Dice,
+	})
+
+	emitHook(hookDiceRolled, room, map[string]any{
+		"player": game.Player{ID: c.ID, Name: c.Name},
+		"dice":   res.Dice,
+		"from":   res.From,
+		"to":     res.To,
+	})
+
+	go notifyTurn(room)
+	return res, nil
 }
 
-/* ===== Turns ===== */
+// pass hands the turn to the next player on the holder's behalf.
+func pass(room string) error {
+	g := getGame(room)
+	if g == nil {
+		return errNoRoom
+	}
+	from, _, err := g.ForcePass()
+	if err != nil {
+		return err
+	}
+	if p, ok := g.Player(from); ok {
+		broadcast(room, &protocol.Event{Text: fmt.Sprintf("%s's turn was passed.", p.Name)})
+	}
+	broadcast(room, snapshot(g))
+	go notifyTurn(room)
+	return nil
+}
 
-func ensureTurnHolder(room string) {
-	mu.Lock()
-	defer mu.Unlock()
-	if turn[room] != nil {
-		return
+// setPaused pauses or resumes a room. Players are told either way.
+func setPaused(room string, paused bool) error {
+	g := getGame(room)
+	if g == nil {
+		return errNoRoom
 	}
-	for cl := range rooms[room] {
-		turn[room] = cl
-		break
+	if !g.SetPaused(paused) {
+		return nil
+	}
+	text := "The game is paused."
+	if !paused {
+		text = "The game has resumed."
 	}
-	if turn[room] != nil {
+	broadcast(room, &protocol.Event{Text: text})
+	broadcast(room, snapshot(g))
+	if paused {
+		emitHook(hookGamePaused, room, nil)
+	} else {
+		emitHook(hookGameResumed, room, nil)
 		go notifyTurn(room)
 	}
+	return nil
 }
 
-func getTurnHolder(room string) *Client {
+// kick disconnects every connection seated as playerID and bars the seat
+// from being resumed. The seat is then freed by onClose, exactly as when
+// the player leaves.
+func kick(room, playerID, reason string) error {
 	mu.Lock()
-	defer mu.Unlock()
-	return turn[room]
+	var seat []*Client
+	for c := range rooms[room] {
+		if c.ID == playerID {
+			seat = append(seat, c)
+		}
+	}
+	if m := lobby[room]; m != nil && len(seat) > 0 {
+		kicked := maps.Clone(m.Kicked)
+		if kicked == nil {
+			kicked = make(map[string]bool)
+		}
+		kicked[playerID] = true
+		m.Kicked = kicked
+	}
+	mu.Unlock()
+	if len(seat) == 0 {
+		return game.ErrNotSeated
+	}
+	if reason == "" {
+		reason = "removed by an admin"
+	}
+	for _, c := range seat {
+		c.send(&protocol.Event{Text: "You were kicked: " + reason})
+		c.close(websocket.ClosePolicyViolation, "kicked")
+	}
+	return nil
 }
 
-func passTurn(room string, current *Client) {
+// closeRoom disconnects everyone in a room and discards it.
+func closeRoom(room string) error {
 	mu.Lock()
-	defer mu.Unlock()
-
-	set := rooms[room]
-	if len(set) == 0 {
-		turn[room] = nil
-		return
+	_, listed := lobby[room]
+	g := games[room]
+	delete(lobby, room)
+	delete(reveals, room)
+	var open []*Client
+	for c := range rooms[room] {
+		open = append(open, c)
 	}
-
-	// Build deterministic list (sort by Name, then ID) so rotation is stable
-	list := make([]*Client, 0, len(set))
-	for c := range set {
-		list = append(list, c)
+	if len(open) == 0 {
+		delete(games, room) // nobody connected, so onClose won't tear it down
+		delete(eventLogs, room)
+		delete(chats, room)
 	}
-	sort.Slice(list, func(i, j int) bool {
-		if list[i].Name == list[j].Name {
-			return list[i].ID < list[j].ID
-		}
-		return list[i].Name < list[j].Name
-	})
+	endReplayLocked(room)
+	mu.Unlock()
+	if g == nil && !listed {
+		return errNoRoom
+	}
+	broadcast(room, &protocol.Event{Text: "This room has been closed."})
+	emitHook(hookRoomClosed, room, map[string]any{"reason": "admin"})
+	for _, c := range open {
+		c.close(websocket.CloseNormalClosure, "room closed")
+	}
+	return nil
+}
 
-	nextIdx := 0
-	for i, c := range list {
-		if c == current {
-			nextIdx = (i + 1) % len(list)
-			break
+// announce sends an admin message to room, or to every room when room is "".
+func announce(room, text string) error {
+	msg := &protocol.AdminMessage{Text: text}
+	if room != "" {
+		if getGame(room) == nil {
+			return errNoRoom
 		}
+		broadcast(room, msg)
+		return nil
 	}
-	turn[room] = list[nextIdx]
-	go notifyTurn(room)
+	for _, room := range openRooms() {
+		broadcast(room, msg)
+	}
+	return nil
 }
 
-func notifyTurn(room string) {
-	holder := getTurnHolder(room)
-	if holder == nil {
-		return
+// snapshot builds the State message for a room.
+func snapshot(g *game.Room) *protocol.State {
+	return stateMsg(g.View())
+}
+
+func stateMsg(v game.View) *protocol.State {
+	return &protocol.State{
+		Players:   v.Players,
+		Positions: v.Positions,
+		Turn:      v.Turn,
+		Host:      v.Host,
+		Paused:    v.Phase == game.PhasePaused,
+		Version:   v.Version,
 	}
-	broadcast(room, map[string]any{"type": "event", "text": fmt.Sprintf("It's %s's turn.", holder.Name)})
-	holder.writeJSON(map[string]any{"type": "yourTurn", "canRoll": true})
 }
 
-/* ===== Logging ===== */
+/* ===== Turns ===== */
+
+func notifyTurn(room string) {
+	g := getGame(room)
+	if g == nil {
+		return
+	}
+	holder, ok := g.Player(g.Turn())
+	if !ok {
+		return
+	}
+	broadcast(room, &protocol.Event{Text: fmt.Sprintf("It's %s's turn.", holder.Name)})
+	canRoll := g.Phase() != game.PhasePaused
 
-func broadcastServerLog(text string) {
-	log.Println(text)
 	mu.Lock()
-	var list []string
-	for room := range rooms {
-		list = append(list, room)
+	var seat []*Client
+	for c := range rooms[room] {
+		if c.ID == holder.ID {
+			seat = append(seat, c)
+		}
 	}
 	mu.Unlock()
-	for _, room := range list {
-		broadcast(room, map[string]any{"type": "serverLog", "text": text})
+	for _, c := range seat {
+		c.send(&protocol.YourTurn{CanRoll: canRoll})
 	}
 }
 
-func broadcastServerLogTo(room, text string) {
-	log.Printf("[%s] %s", room, text)
-	broadcast(room, map[string]any{"type": "serverLog", "text": text})
-}
-
 /* ===== Client write helpers ===== */
 
-func (c *Client) writeJSON(v any) {
-	b, _ := json.Marshal(v)
+func (c *Client) send(msg protocol.Message) {
+	b, err := protocol.Marshal(msg)
+	if err != nil {
+		clientLog(c).Error("send", "err", err)
+		return
+	}
 	c.writeRaw(b)
+	messagesOut.Inc(protocol.Type(msg))
 }
 
-func (c *Client) writeRaw(b []byte) {
-	c.SendMu.Lock()
-	defer c.SendMu.Unlock()
-	_ = c.Conn.WriteMessage(websocket.TextMessage, b)
+func newClient(cn *websocket.Conn, ip string) *Client {
+	return &Client{
+		Conn:     cn,
+		IP:       ip,
+		Limit:    ratelimit.NewBucket(connRate, connBurst),
+		out:      make(chan []byte, sendBuffer),
+		done:     make(chan struct{}),
+		pumpDone: make(chan struct{}),
+	}
 }
 
-/* ===== Utils ===== */
-
-func short(id string) string {
-	if len(id) <= 6 {
-		return id
+// writeRaw queues b without blocking. A client that has fallen a whole
+// buffer behind is evicted rather than allowed to stall the sender; once
+// closed, it gets nothing more.
+func (c *Client) writeRaw(b []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.out <- b:
+	default:
+		clientLog(c).Warn("evicting slow client: send buffer full", "name", c.Name)
+		c.close(websocket.ClosePolicyViolation, "send buffer full")
+		if c.Conn != nil {
+			_ = c.Conn.SetReadDeadline(time.Now()) // unblock the read loop so onClose runs
+		}
 	}
-	return id[:6]
 }
 
-/* ===== Debug endpoint ===== */
-
-type PlayerInfo struct {
-	PlayerID string `json:"playerId"`
-	Name     string `json:"name"`
-	RoomID   string `json:"roomId"`
-	Pos      int    `json:"pos"`
+// close stops the writer; it flushes what is already queued, then sends a
+// close frame with code and reason. Only the first call has any effect.
+func (c *Client) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeMsg = websocket.FormatCloseMessage(code, reason)
+		close(c.done)
+	})
 }
 
-func debugPlayersHTTP(w http.ResponseWriter, r *http.Request) {
-	room := r.URL.Query().Get("room")
-	w.Header().Set("Content-Type", "application/json")
-
-	mu.Lock()
-	defer mu.Unlock()
-
-	if room != "" {
-		var list []PlayerInfo
-		set := rooms[room]
-		for c := range set {
-			pos := 0
-			if positions[room] != nil {
-				pos = positions[room][c.ID]
+// writePump is the only goroutine that writes data frames to the socket.
+// It also sends the keepalive pings.
+func (c *Client) writePump() {
+	defer close(c.pumpDone)
+	ping := time.NewTicker(pingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case b := <-c.out:
+			if !c.write(b) {
+				return
 			}
-			list = append(list, PlayerInfo{
-				PlayerID: c.ID,
-				Name:     c.Name,
-				RoomID:   room,
-				Pos:      pos,
-			})
-		}
-		_ = json.NewEncoder(w).Encode(map[string]any{"room": room, "players": list})
-		return
-	}
-
-	out := map[string][]PlayerInfo{}
-	for rm, set := range rooms {
-		for c := range set {
-			pos := 0
-			if positions[rm] != nil {
-				pos = positions[rm][c.ID]
+		case <-ping.C:
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.close(websocket.CloseGoingAway, "write failed")
+				_ = c.Conn.Close()
+				return
+			}
+		case <-c.done:
+			for {
+				select {
+				case b := <-c.out:
+					if !c.write(b) {
+						return
+					}
+				default:
+					_ = c.Conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(writeWait))
+					// Give the peer a moment to answer the close, then
+					// let the read loop fail.
+					_ = c.Conn.SetReadDeadline(time.Now().Add(closeGrace))
+					return
+				}
 			}
-			out[rm] = append(out[rm], PlayerInfo{
-				PlayerID: c.ID,
-				Name:     c.Name,
-				RoomID:   rm,
-				Pos:      pos,
-			})
 		}
 	}
-	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (c *Client) write(b []byte) bool {
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.Conn.WriteMessage(websocket.TextMessage, b); err != nil {
+		c.close(websocket.CloseGoingAway, "write failed") // later broadcasts skip it
+		_ = c.Conn.Close()                                // ends the read loop too
+		return false
+	}
+	return true
+}
+
+/* ===== Utils ===== */
+
+func short(id string) string {
+	if len(id) <= 6 {
+		return id
+	}
+	return id[:6]
 }
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"monopoly/errcode"
+	"monopoly/protocol"
+	"monopoly/session"
+)
+
+func TestMain(m *testing.M) {
+	sessions = session.NewSigner(nil, time.Hour)
+	os.Exit(m.Run())
+}
+
+// newLobbyRoom creates a room through the lobby, closed when t ends.
+func newLobbyRoom(t *testing.T, password string) string {
+	t.Helper()
+	m := createRoom("Test", false, password)
+	t.Cleanup(func() { _ = closeRoom(m.ID) })
+	return m.ID
+}
+
+// newTestConn returns a connectionless client, torn down when t ends.
+func newTestConn(t *testing.T) *Client {
+	t.Helper()
+	c := newClient(nil, "192.0.2.1")
+	t.Cleanup(func() { onClose(c) })
+	return c
+}
+
+// received drains the messages queued for c.
+func received(c *Client) []json.RawMessage {
+	var out []json.RawMessage
+	for {
+		select {
+		case b := <-c.out:
+			out = append(out, b)
+		default:
+			return out
+		}
+	}
+}
+
+// receivedType decodes the first queued message of type typ into v.
+func receivedType(c *Client, typ string, v any) bool {
+	for _, b := range received(c) {
+		var env protocol.Envelope
+		if json.Unmarshal(b, &env) == nil && env.Type == typ {
+			return json.Unmarshal(b, v) == nil
+		}
+	}
+	return false
+}
+
+// seat resumes c into room as playerID and returns its session token.
+func seat(t *testing.T, c *Client, room, playerID, password string) string {
+	t.Helper()
+	if _, err := dispatch(c, &protocol.Resume{PlayerID: playerID, Name: playerID, Room: room, Password: password}); err != nil {
+		t.Fatalf("resume %s: %v", playerID, err)
+	}
+	var s protocol.Session
+	if !receivedType(c, "session", &s) {
+		t.Fatal("no session token after resume")
+	}
+	return s.Token
+}
+
+// startTestServer serves every endpoint on a local listener.
+func startTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(newMux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// dialWS opens a WebSocket to srv, closed when t ends.
+func dialWS(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func wsSend(t *testing.T, ws *websocket.Conn, msg string) {
+	t.Helper()
+	if err := ws.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatalf("send %s: %v", msg, err)
+	}
+}
+
+// wsNext reads until a message of type typ arrives and decodes it into v.
+func wsNext(t *testing.T, ws *websocket.Conn, typ string, v any) {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, b, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var env protocol.Envelope
+		if json.Unmarshal(b, &env) == nil && env.Type == typ {
+			if err := json.Unmarshal(b, v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+}
+
+func errCode(err error) errcode.Code {
+	var e *errcode.Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+func TestParseOrigins(t *testing.T) {
+	p := parseOrigins(" https://game.example/ , http://localhost:*,,https://b.example:8443 ")
+	if p.any {
+		t.Error("no \"*\" entry, but any is set")
+	}
+	for _, o := range []string{"https://game.example", "https://b.example:8443"} {
+		if !p.exact[o] {
+			t.Errorf("exact origin %s missing", o)
+		}
+	}
+	if !p.anyPort["http://localhost"] || len(p.anyPort) != 1 {
+		t.Errorf("anyPort = %v, want just http://localhost", p.anyPort)
+	}
+	if len(p.exact) != 2 {
+		t.Errorf("exact = %v, want 2 entries", p.exact)
+	}
+	if !parseOrigins("https://a.example,*").any {
+		t.Error("\"*\" entry did not allow any origin")
+	}
+}
+
+func TestOriginAllows(t *testing.T) {
+	p := parseOrigins("https://game.example,http://localhost:*")
+	tests := []struct {
+		origin string
+		host   string // the request's Host header
+		want   bool
+	}{
+		{"", "monopoly.example", true}, // not a browser
+		{"https://game.example", "monopoly.example", true},
+		{"https://game.example/", "monopoly.example", false},
+		{"http://game.example", "monopoly.example", false},
+		{"https://game.example:8443", "monopoly.example", false},
+		{"https://evil.example", "monopoly.example", false},
+		{"https://game.example.evil.example", "monopoly.example", false},
+		{"http://localhost:3000", "monopoly.example", true},
+		{"http://localhost", "monopoly.example", true},
+		{"https://localhost:3000", "monopoly.example", false},
+		{"http://localhost.evil.example:3000", "monopoly.example", false},
+		{"https://monopoly.example", "monopoly.example", true}, // same origin
+		{"http://MONOPOLY.example:8081", "monopoly.example:8081", true},
+		{"https://monopoly.example:9999", "monopoly.example:8081", false},
+		{"null", "monopoly.example", false},
+		{"not a url", "monopoly.example", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := p.allows(r); got != tt.want {
+			t.Errorf("Origin %q to %s: allows = %v, want %v", tt.origin, tt.host, got, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	if !parseOrigins("*").allows(r) {
+		t.Error("\"*\" rejected an origin")
+	}
+}
+
+func TestResumeRetry(t *testing.T) {
+	room := newLobbyRoom(t, "open sesame")
+	c := newTestConn(t)
+
+	closeConn, err := dispatch(c, &protocol.Resume{PlayerID: "p1", Name: "A", Room: room, Password: "wrong"})
+	if errCode(err) != errcode.Unauthorized || closeConn {
+		t.Fatalf("wrong password: err %v, closeConn %v; want UNAUTHORIZED on an open socket", err, closeConn)
+	}
+	closeConn, err = dispatch(c, &protocol.Resume{PlayerID: "p1", Name: "A", Room: "NOSUCH", Password: "open sesame"})
+	if errCode(err) != errcode.NotFound || closeConn {
+		t.Fatalf("unknown room: err %v, closeConn %v", err, closeConn)
+	}
+	tok := seat(t, c, room, "p1", "open sesame")
+	if tok == "" {
+		t.Fatal("retried resume got no token")
+	}
+
+	// A seated connection can't take a second seat.
+	closeConn, err = dispatch(c, &protocol.Resume{PlayerID: "p2", Name: "B", Room: room, Password: "open sesame"})
+	if !errors.Is(err, errAlreadyJoined) || closeConn {
+		t.Fatalf("second resume: err %v, closeConn %v", err, closeConn)
+	}
+	if g := getGame(room); len(g.Players()) != 1 || c.ID != "p1" {
+		t.Errorf("players %v, connection seated as %q; want only p1", g.Players(), c.ID)
+	}
+}
+
+func TestSeatCommandsNeedToken(t *testing.T) {
+	room := newLobbyRoom(t, "")
+	c := newTestConn(t)
+	tok := seat(t, c, room, "p1", "")
+	other := newTestConn(t)
+	otherTok := seat(t, other, room, "p2", "")
+
+	tests := []struct {
+		name  string
+		token string
+		want  errcode.Code
+	}{
+		{"missing", "", errcode.Unauthorized},
+		{"garbage", "nope", errcode.Unauthorized},
+		{"another seat's", otherTok, errcode.Unauthorized},
+		{"own", tok, ""},
+	}
+	for _, tt := range tests {
+		msgs := []protocol.Message{
+			&protocol.Chat{Token: tt.token, Text: "hi"},
+			&protocol.React{Token: tt.token, Emote: "clap"},
+			&protocol.Mute{Token: tt.token, PlayerID: "p2"},
+			&protocol.Roll{Token: tt.token},
+		}
+		for _, msg := range msgs {
+			if _, err := dispatch(c, msg); errCode(err) != tt.want {
+				t.Errorf("%s token, %T: err %v, want %q", tt.name, msg, err, tt.want)
+			}
+		}
+		received(c)
+	}
+}
+
+func TestRequestCorrelation(t *testing.T) {
+	srv := startTestServer(t)
+	ws := dialWS(t, srv)
+	room := newLobbyRoom(t, "")
+
+	wsSend(t, ws, `{"type":"resume","v":1,"id":"j1","room":"`+room+`","playerId":"p1","name":"A"}`)
+	var ack protocol.Ack
+	wsNext(t, ws, "ack", &ack)
+	if ack.Ref != "j1" {
+		t.Errorf("resume ack ref %q, want j1", ack.Ref)
+	}
+
+	tests := []struct {
+		msg  string
+		ref  string
+		code errcode.Code
+	}{
+		{`{"type":"roll","v":1,"id":"r1","token":"forged"}`, "r1", errcode.Unauthorized},
+		{`{"type":"teleport","v":1,"id":"t1"}`, "t1", errcode.BadMessage},
+		{`{"type":"roll","v":9,"id":"v9","token":""}`, "v9", errcode.BadMessage},
+		{`{"id":"broken",`, "", errcode.BadMessage},
+		{`{"type":"chat","v":1,"text":"no id"}`, "", errcode.Unauthorized},
+	}
+	for _, tt := range tests {
+		wsSend(t, ws, tt.msg)
+		var e protocol.Error
+		wsNext(t, ws, "error", &e)
+		if e.Ref != tt.ref || e.Code != tt.code {
+			t.Errorf("%s: error ref %q code %s, want %q %s", tt.msg, e.Ref, e.Code, tt.ref, tt.code)
+		}
+	}
+
+	wsSend(t, ws, `{"type":"ping","v":1,"id":"p7","t":42}`)
+	var pong protocol.Pong
+	wsNext(t, ws, "pong", &pong)
+	wsNext(t, ws, "ack", &ack)
+	if pong.T != 42 || ack.Ref != "p7" {
+		t.Errorf("pong t=%d ack ref %q, want 42 and p7", pong.T, ack.Ref)
+	}
+}
+
+func TestSlowClientEvicted(t *testing.T) {
+	c := newClient(nil, "192.0.2.1")
+	for range sendBuffer {
+		c.writeRaw([]byte(`{}`))
+	}
+	select {
+	case <-c.done:
+		t.Fatal("evicted with room left in the buffer")
+	default:
+	}
+	c.writeRaw([]byte(`{}`))
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("a full buffer did not evict the client")
+	}
+
+	received(c)
+	c.writeRaw([]byte(`{}`))
+	if len(c.out) != 0 {
+		t.Error("a closed client still queues messages")
+	}
+}
+
+func TestWriteFailureClosesClient(t *testing.T) {
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- ws
+	}))
+	defer srv.Close()
+	peer := dialWS(t, srv)
+	c := newClient(<-conns, "192.0.2.1")
+	if !c.write([]byte(`{}`)) {
+		t.Fatal("write to a live peer failed")
+	}
+
+	peer.Close()
+	_ = c.Conn.UnderlyingConn().Close()
+	if c.write([]byte(`{}`)) {
+		t.Fatal("write to a closed socket succeeded")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("a failed write left the client open")
+	}
+	for range 2 * sendBuffer {
+		c.writeRaw([]byte(`{}`)) // would evict (and warn) again on every call
+	}
+	if len(c.out) != 0 {
+		t.Errorf("%d messages queued for a dead client", len(c.out))
+	}
+}
+
+// shortKeepalive makes the server ping every 50ms and give up on a peer
+// silent for 200ms.
+func shortKeepalive(t *testing.T) {
+	wait, period := pongWait, pingPeriod
+	pongWait, pingPeriod = 200*time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { pongWait, pingPeriod = wait, period })
+}
+
+func TestKeepalive(t *testing.T) {
+	shortKeepalive(t)
+	srv := startTestServer(t)
+
+	t.Run("answering pings", func(t *testing.T) {
+		ws := dialWS(t, srv)
+		pings := make(chan struct{}, 100)
+		ws.SetPingHandler(func(data string) error {
+			pings <- struct{}{}
+			return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		errs := make(chan error, 1)
+		go func() {
+			for {
+				if _, _, err := ws.ReadMessage(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		select {
+		case err := <-errs:
+			t.Fatalf("connection dropped while answering pings: %v", err)
+		case <-time.After(3 * pongWait):
+		}
+		if len(pings) < 3 {
+			t.Errorf("got %d pings in %v, want several", len(pings), 3*pongWait)
+		}
+	})
+
+	t.Run("silent peer", func(t *testing.T) {
+		ws := dialWS(t, srv)
+		ws.SetPingHandler(func(string) error { return nil }) // never pong
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		start := time.Now()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				break
+			}
+		}
+		if d := time.Since(start); d < pongWait || d > 2*time.Second {
+			t.Errorf("silent peer dropped after %v, want about %v", d, pongWait)
+		}
+	})
+}
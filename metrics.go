@@ -0,0 +1,34 @@
+package main
+
+import (
+	"monopoly/metrics"
+)
+
+/* ===== Metrics ===== */
+
+// registry is served at /metrics in the Prometheus text format.
+var registry = metrics.NewRegistry()
+
+var (
+	messagesIn     = registry.CounterVec("monopoly_ws_messages_in_total", "Inbound WebSocket messages by type (\"invalid\" if undecodable).", "type")
+	messagesOut    = registry.CounterVec("monopoly_ws_messages_out_total", "Outbound messages by type, over WebSocket or SSE, counted per recipient.", "type")
+	broadcastTime  = registry.Histogram("monopoly_broadcast_seconds", "Time to fan one message out to every client in a room.", []float64{1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 0.01, 0.05, 0.1})
+	rolls          = registry.Counter("monopoly_rolls_total", "Dice rolls applied.")
+	rejected       = registry.CounterVec("monopoly_rejected_total", "Rejected commands and requests by error code.", "code")
+	rateLimitDrops = registry.CounterVec("monopoly_rate_limited_total", "Requests dropped by a throttle, by scope (conn, ip, player, chat, reaction).", "scope")
+	gamesCompleted = registry.Counter("monopoly_games_completed_total", "Rooms closed after their last player left.")
+	hookDeliveries = registry.CounterVec("monopoly_webhook_deliveries_total", "Finished webhook deliveries by result (delivered, failed).", "result")
+)
+
+func init() {
+	registry.GaugeFunc("monopoly_rooms", "Open rooms.", func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return float64(len(rooms))
+	})
+	registry.GaugeFunc("monopoly_clients", "Open connections: WebSockets and SSE streams.", func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return float64(len(conns))
+	})
+}
@@ -0,0 +1,215 @@
+// Package metrics is a small, dependency-free set of counters, gauges and
+// histograms written in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds metrics in registration order.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mu.Unlock()
+}
+
+// WriteTo writes every metric in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	list := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range list {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP lets the registry be mounted as a scrape endpoint.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+/* ===== Counter ===== */
+
+type Counter struct {
+	name, help string
+	v          atomic.Uint64
+}
+
+func (r *Registry) Counter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.add(c)
+	return c
+}
+
+func (c *Counter) Inc()         { c.v.Add(1) }
+func (c *Counter) Add(n uint64) { c.v.Add(n) }
+
+func (c *Counter) write(w *bufio.Writer) {
+	header(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.v.Load())
+}
+
+// CounterVec is a family of counters told apart by one label.
+type CounterVec struct {
+	name, help, label string
+
+	mu sync.Mutex
+	m  map[string]*atomic.Uint64
+}
+
+func (r *Registry) CounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{name: name, help: help, label: label, m: make(map[string]*atomic.Uint64)}
+	r.add(v)
+	return v
+}
+
+func (v *CounterVec) Inc(value string) { v.Add(value, 1) }
+
+func (v *CounterVec) Add(value string, n uint64) {
+	v.mu.Lock()
+	c := v.m[value]
+	if c == nil {
+		c = new(atomic.Uint64)
+		v.m[value] = c
+	}
+	v.mu.Unlock()
+	c.Add(n)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	header(w, v.name, v.help, "counter")
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.m))
+	for k := range v.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	counts := make([]uint64, len(keys))
+	for i, k := range keys {
+		counts[i] = v.m[k].Load()
+	}
+	v.mu.Unlock()
+	for i, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", v.name, v.label, escape(k), counts[i])
+	}
+}
+
+/* ===== Gauge ===== */
+
+// gaugeFunc is sampled at scrape time.
+type gaugeFunc struct {
+	name, help string
+	f          func() float64
+}
+
+// GaugeFunc registers a gauge whose value is read from f on every scrape.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.add(&gaugeFunc{name: name, help: help, f: f})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	header(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.f()))
+}
+
+/* ===== Histogram ===== */
+
+type Histogram struct {
+	name, help string
+	bounds     []float64 // upper bounds, ascending
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Histogram registers a histogram with the given bucket upper bounds; a
+// +Inf bucket is implied.
+func (r *Registry) Histogram(name, help string, bounds []float64) *Histogram {
+	h := &Histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+	r.add(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	counts := append([]uint64(nil), h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	header(w, h.name, h.help, "histogram")
+	var cum uint64
+	for i, b := range h.bounds {
+		cum += counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, count)
+}
+
+/* ===== Helpers ===== */
+
+func header(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string { return labelEscaper.Replace(s) }
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
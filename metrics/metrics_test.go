@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("rolls_total", "Dice rolls.")
+	v := r.CounterVec("rejected_total", "Rejections by code.", "code")
+	r.GaugeFunc("rooms", "Open rooms.", func() float64 { return 2.5 })
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+
+	c.Inc()
+	c.Add(2)
+	v.Inc("NOT_YOUR_TURN")
+	v.Add(`say "hi"\`+"\n", 2)
+	v.Inc("BAD_MESSAGE")
+	h.Observe(0.05)
+	h.Observe(0.1) // an upper bound is inclusive
+	h.Observe(0.5)
+	h.Observe(7)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP rolls_total Dice rolls.
+# TYPE rolls_total counter
+rolls_total 3
+# HELP rejected_total Rejections by code.
+# TYPE rejected_total counter
+rejected_total{code="BAD_MESSAGE"} 1
+rejected_total{code="NOT_YOUR_TURN"} 1
+rejected_total{code="say \"hi\"\\\n"} 2
+# HELP rooms Open rooms.
+# TYPE rooms gauge
+rooms 2.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 7.65
+latency_seconds_count 4
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil || n != int64(len(want)) {
+		t.Errorf("WriteTo = %d, %v, want %d bytes", n, err, len(want))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// metricLine returns the sample line for name from the metrics output.
+func metricLine(t *testing.T, name string) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(strings.NewReader(b.String()))
+	for sc.Scan() {
+		if line := sc.Text(); strings.HasPrefix(line, name+" ") || strings.HasPrefix(line, name+"{") {
+			return line
+		}
+	}
+	t.Fatalf("no %s in the metrics output", name)
+	return ""
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv := startTestServer(t)
+	var before int
+	fmt.Sscanf(metricLine(t, "monopoly_clients"), "monopoly_clients %d", &before)
+	ws := newTestConn(t) // stand-ins for a WebSocket and an SSE stream
+	sse := newTestConn(t)
+	trackConn(ws)
+	trackConn(sse)
+	defer untrackConn(sse)
+	defer untrackConn(ws)
+	rejected.Inc("TEST_CODE")
+
+	res, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	body := string(b)
+	for _, want := range []string{
+		"# HELP monopoly_clients Open connections: WebSockets and SSE streams.\n",
+		fmt.Sprintf("# TYPE monopoly_clients gauge\nmonopoly_clients %d\n", before+2),
+		`monopoly_rejected_total{code="TEST_CODE"} `,
+		"# TYPE monopoly_broadcast_seconds histogram\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics lacks %q", want)
+		}
+	}
+}
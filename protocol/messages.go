@@ -0,0 +1,309 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"monopoly/dice"
+	"monopoly/errcode"
+	"monopoly/game"
+)
+
+/* ===== Inbound ===== */
+
+// Resume seats the connection in a room, or reclaims a seat with its token.
+// Room is a join code from POST /rooms.
+type Resume struct {
+	Envelope
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	Room     string `json:"room"`
+	Token    string `json:"token,omitempty"`    // required to reclaim a known seat
+	Password string `json:"password,omitempty"` // for password-protected rooms
+}
+
+func (m *Resume) Validate() error {
+	if m.PlayerID == "" {
+		return errors.New("playerId is required")
+	}
+	if m.Room == "" {
+		return errors.New("room is required")
+	}
+	return nil
+}
+
+// Who asks for the roster of a room (the connection's room when empty).
+// Outside its own room a connection only sees rooms without a password.
+type Who struct {
+	Envelope
+	Room string `json:"room,omitempty"`
+}
+
+type SubscribeLogs struct {
+	Envelope
+	Room string `json:"room,omitempty"`
+}
+
+// Sync asks for a full State snapshot of the connection's room.
+type Sync struct {
+	Envelope
+	Room string `json:"room,omitempty"`
+}
+
+type Roll struct {
+	Envelope
+	Token   string `json:"token"`
+	Entropy string `json:"entropy,omitempty"` // mixed into fair dice
+}
+
+func (m *Roll) token() *string { return &m.Token }
+
+// Reveal asks fair dice to disclose their seed and commit to a new one.
+type Reveal struct {
+	Envelope
+	Token string `json:"token"`
+}
+
+func (m *Reveal) token() *string { return &m.Token }
+
+// Chat sends Text to the room, or privately to player To.
+type Chat struct {
+	Envelope
+	Token string `json:"token"`
+	Text  string `json:"text"`
+	To    string `json:"to,omitempty"` // playerID for a whisper
+}
+
+func (m *Chat) token() *string { return &m.Token }
+
+// MaxChatLength is the longest chat text accepted, in characters.
+const MaxChatLength = 500
+
+func (m *Chat) Validate() error {
+	if strings.TrimSpace(m.Text) == "" {
+		return errors.New("text is required")
+	}
+	if utf8.RuneCountInString(m.Text) > MaxChatLength {
+		return fmt.Errorf("text is longer than %d characters", MaxChatLength)
+	}
+	return nil
+}
+
+// Mute silences (or, with Muted false, unmutes) a player's chat. Only the
+// room's host may send it.
+type Mute struct {
+	Envelope
+	Token    string `json:"token"`
+	PlayerID string `json:"playerId"`
+	Muted    bool   `json:"muted"`
+}
+
+func (m *Mute) token() *string { return &m.Token }
+
+func (m *Mute) Validate() error {
+	if m.PlayerID == "" {
+		return errors.New("playerId is required")
+	}
+	return nil
+}
+
+// React shows an emote from the catalog to the room, optionally aimed at
+// player To (e.g. after their unlucky roll).
+type React struct {
+	Envelope
+	Token string `json:"token"`
+	Emote string `json:"emote"`
+	To    string `json:"to,omitempty"`
+}
+
+func (m *React) token() *string { return &m.Token }
+
+// Emotes is the fixed reaction catalog: name -> glyph.
+var Emotes = map[string]string{
+	"clap":     "👏",
+	"laugh":    "😂",
+	"wow":      "😮",
+	"cry":      "😢",
+	"angry":    "😠",
+	"fire":     "🔥",
+	"money":    "💰",
+	"skull":    "💀",
+	"thumbs":   "👍",
+	"facepalm": "🤦",
+}
+
+func (m *React) Validate() error {
+	if _, ok := Emotes[m.Emote]; !ok {
+		return fmt.Errorf("unknown emote %q", m.Emote)
+	}
+	return nil
+}
+
+type Ping struct {
+	Envelope
+	T    int64  `json:"t,omitempty"` // client clock, ms
+	Room string `json:"room,omitempty"`
+}
+
+type Leave struct {
+	Envelope
+	PlayerID string `json:"playerId,omitempty"`
+	Room     string `json:"room,omitempty"`
+}
+
+/* ===== Outbound ===== */
+
+// Session carries the token that binds the connection to its seat.
+type Session struct {
+	Envelope
+	Token string `json:"token"`
+}
+
+type Players struct {
+	Envelope
+	List []game.Player `json:"list"`
+}
+
+type PlayerJoined struct {
+	Envelope
+	Player game.Player `json:"player"`
+}
+
+type PlayerLeft struct {
+	Envelope
+	Player game.Player `json:"player"`
+}
+
+// State is a snapshot of the room. Version increases with every change, so
+// clients can drop snapshots older than one they already applied.
+type State struct {
+	Envelope
+	Players   []game.Player  `json:"players"`
+	Positions map[string]int `json:"positions"`
+	Turn      string         `json:"turn,omitempty"` // playerID holding the turn
+	Host      string         `json:"host,omitempty"` // playerID who can mute
+	Paused    bool           `json:"paused,omitempty"`
+	Version   uint64         `json:"version"`
+}
+
+type YourTurn struct {
+	Envelope
+	CanRoll bool `json:"canRoll"`
+}
+
+type Move struct {
+	Envelope
+	PlayerID string `json:"playerId"`
+	From     int    `json:"from"`
+	To       int    `json:"to"`
+	Dice     [2]int `json:"dice"`
+}
+
+// Event is game narration shown to players.
+type Event struct {
+	Envelope
+	Text string `json:"text"`
+}
+
+// ChatMessage is a chat line. To is set on whispers, which only the sender
+// and recipient receive. Time is unix ms.
+type ChatMessage struct {
+	Envelope
+	From game.Player `json:"from"`
+	Text string      `json:"text"`
+	To   string      `json:"to,omitempty"`
+	Time int64       `json:"time"`
+}
+
+// ChatHistory answers Sync with the recent chat the recipient may see,
+// oldest first, and who is muted.
+type ChatHistory struct {
+	Envelope
+	Messages []ChatMessage `json:"messages"`
+	Muted    []string      `json:"muted,omitempty"`
+}
+
+// Muted tells the room a player was muted or unmuted by the host.
+type Muted struct {
+	Envelope
+	PlayerID string `json:"playerId"`
+	Muted    bool   `json:"muted"`
+}
+
+// Reaction is a transient emote. Clients show it briefly rather than in the
+// game log; it is not part of the room's event history.
+type Reaction struct {
+	Envelope
+	From  game.Player `json:"from"`
+	Emote string      `json:"emote"`
+	To    string      `json:"to,omitempty"`
+	Time  int64       `json:"time"`
+}
+
+// Replay describes the recording a replay room is playing back: sent to
+// spectators when they arrive, when the speed changes and when it ends.
+type Replay struct {
+	Envelope
+	Recording string  `json:"recording"`
+	Speed     float64 `json:"speed"`
+	Position  int     `json:"position"` // events re-emitted so far
+	Total     int     `json:"total"`
+	Finished  bool    `json:"finished,omitempty"`
+}
+
+// AdminMessage is an announcement from the server operators.
+type AdminMessage struct {
+	Envelope
+	Text string `json:"text"`
+}
+
+type ServerLog struct {
+	Envelope
+	Text string `json:"text"`
+}
+
+type FairCommit struct {
+	Envelope
+	Commitment string `json:"commitment"`
+}
+
+type FairReveal struct {
+	Envelope
+	Record dice.Record `json:"record"`
+}
+
+// Pong answers a Ping. T echoes the client's clock so it can measure the
+// round trip; ServerTime is the server clock in ms.
+type Pong struct {
+	Envelope
+	T          int64 `json:"t,omitempty"`
+	ServerTime int64 `json:"serverTime"`
+}
+
+// ServerShutdown warns that the server is going away. ReturnAt is when it
+// expects to be back (unix ms); clients should reconnect after it.
+type ServerShutdown struct {
+	Envelope
+	Reason   string `json:"reason"`
+	ReturnAt int64  `json:"returnAt,omitempty"`
+}
+
+// Ack confirms that the inbound message with id Ref was handled. It is only
+// sent for messages that carried an id.
+type Ack struct {
+	Envelope
+	Ref string `json:"ref"`
+}
+
+// Error reports a rejected message or command. Ref is the id of the inbound
+// message it answers, when it had one. HTTP endpoints reply with the same
+// shape.
+type Error struct {
+	Envelope
+	Ref          string       `json:"ref,omitempty"`
+	Code         errcode.Code `json:"code"`
+	Message      string       `json:"message"`
+	RetryAfterMs int64        `json:"retryAfterMs,omitempty"`
+}
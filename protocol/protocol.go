@@ -0,0 +1,162 @@
+// Package protocol defines every WebSocket message the server sends or
+// accepts. Each message is a flat JSON object carrying the Envelope fields
+// next to its own.
+//
+//go:generate go run ../cmd/schemagen -o schema.json
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Version is the protocol version stamped on every outbound message.
+// Inbound messages may omit "v"; anything newer than Version is rejected.
+const Version = 1
+
+// Envelope is common to every message.
+type Envelope struct {
+	Type string `json:"type"`
+	V    int    `json:"v"`
+	ID   string `json:"id,omitempty"`
+}
+
+func (e *Envelope) envelope() *Envelope { return e }
+
+// RequestID returns the client-chosen id of an inbound message.
+func RequestID(m Message) string {
+	return m.envelope().ID
+}
+
+// Type returns the type an inbound message was decoded as, or the type
+// Marshal stamped on an outbound one.
+func Type(m Message) string {
+	return m.envelope().Type
+}
+
+// SessionToken returns a pointer to the session token carried by an inbound
+// message that acts on the sender's seat, or nil for one that does not.
+func SessionToken(m Message) *string {
+	if a, ok := m.(interface{ token() *string }); ok {
+		return a.token()
+	}
+	return nil
+}
+
+// PeekID extracts the id from a message that may not decode, so even a
+// BAD_MESSAGE reply can reference the request.
+func PeekID(data []byte) string {
+	var env struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(data, &env)
+	return env.ID
+}
+
+// Message is implemented by pointers to every message struct.
+type Message interface {
+	envelope() *Envelope
+}
+
+/* ===== Registry ===== */
+
+// Inbound lists the messages clients may send, by type.
+var Inbound = map[string]Message{
+	"resume":        &Resume{},
+	"who":           &Who{},
+	"subscribeLogs": &SubscribeLogs{},
+	"sync":          &Sync{},
+	"roll":          &Roll{},
+	"reveal":        &Reveal{},
+	"chat":          &Chat{},
+	"mute":          &Mute{},
+	"react":         &React{},
+	"ping":          &Ping{},
+	"leave":         &Leave{},
+}
+
+// Outbound lists the messages the server sends, by type.
+var Outbound = map[string]Message{
+	"session":        &Session{},
+	"players":        &Players{},
+	"playerJoined":   &PlayerJoined{},
+	"playerLeft":     &PlayerLeft{},
+	"state":          &State{},
+	"yourTurn":       &YourTurn{},
+	"move":           &Move{},
+	"event":          &Event{},
+	"serverLog":      &ServerLog{},
+	"adminMessage":   &AdminMessage{},
+	"chat":           &ChatMessage{},
+	"chatHistory":    &ChatHistory{},
+	"muted":          &Muted{},
+	"reaction":       &Reaction{},
+	"replay":         &Replay{},
+	"fairCommit":     &FairCommit{},
+	"fairReveal":     &FairReveal{},
+	"pong":           &Pong{},
+	"serverShutdown": &ServerShutdown{},
+	"ack":            &Ack{},
+	"error":          &Error{},
+}
+
+var outboundNames = func() map[reflect.Type]string {
+	m := make(map[reflect.Type]string, len(Outbound))
+	for name, msg := range Outbound {
+		m[reflect.TypeOf(msg)] = name
+	}
+	return m
+}()
+
+/* ===== Encoding ===== */
+
+// Marshal stamps msg's envelope with its type and the current version and
+// encodes it. msg must be one of the Outbound messages.
+func Marshal(msg Message) ([]byte, error) {
+	name, ok := outboundNames[reflect.TypeOf(msg)]
+	if !ok {
+		return nil, fmt.Errorf("protocol: %T is not an outbound message", msg)
+	}
+	e := msg.envelope()
+	e.Type = name
+	e.V = Version
+	return json.Marshal(msg)
+}
+
+var (
+	ErrUnknownType = errors.New("unknown message type")
+	ErrVersion     = fmt.Errorf("unsupported protocol version (server speaks v%d)", Version)
+)
+
+// Decode parses one inbound message into its typed struct. Unknown types,
+// unknown fields, unsupported versions and messages that fail validation
+// are errors.
+func Decode(data []byte) (Message, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("malformed message: %v", err)
+	}
+	proto, ok := Inbound[env.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownType, env.Type)
+	}
+	if env.V > Version || env.V < 0 {
+		return nil, ErrVersion
+	}
+
+	msg := reflect.New(reflect.TypeOf(proto).Elem()).Interface().(Message)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(msg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", env.Type, err)
+	}
+	if v, ok := msg.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", env.Type, err)
+		}
+	}
+	return msg, nil
+}
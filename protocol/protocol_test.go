@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Message
+	}{
+		{"resume", `{"type":"resume","v":1,"id":"r1","playerId":"p1","name":"A","room":"ABC123"}`,
+			&Resume{Envelope: Envelope{Type: "resume", V: 1, ID: "r1"}, PlayerID: "p1", Name: "A", Room: "ABC123"}},
+		{"version omitted", `{"type":"roll","token":"t"}`,
+			&Roll{Envelope: Envelope{Type: "roll"}, Token: "t"}},
+		{"chat", `{"type":"chat","v":1,"text":"hi","to":"p2"}`,
+			&Chat{Envelope: Envelope{Type: "chat", V: 1}, Text: "hi", To: "p2"}},
+		{"react", `{"type":"react","emote":"clap"}`,
+			&React{Envelope: Envelope{Type: "react"}, Emote: "clap"}},
+		{"leave", `{"type":"leave","v":1}`, &Leave{Envelope: Envelope{Type: "leave", V: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			g, _ := json.Marshal(got)
+			w, _ := json.Marshal(tt.want)
+			if string(g) != string(w) {
+				t.Errorf("Decode = %s, want %s", g, w)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // error substring
+		is   error
+	}{
+		{"not json", `roll`, "malformed message", nil},
+		{"not an object", `["roll"]`, "malformed message", nil},
+		{"no type", `{"v":1}`, "unknown message type", ErrUnknownType},
+		{"unknown type", `{"type":"buyEverything","v":1}`, "unknown message type", ErrUnknownType},
+		{"outbound type", `{"type":"state","v":1}`, "unknown message type", ErrUnknownType},
+		{"newer version", `{"type":"roll","v":2,"token":"t"}`, "unsupported protocol version", ErrVersion},
+		{"negative version", `{"type":"roll","v":-1,"token":"t"}`, "unsupported protocol version", ErrVersion},
+		{"unknown field", `{"type":"roll","v":1,"token":"t","dice":[6,6]}`, `unknown field "dice"`, nil},
+		{"wrong field type", `{"type":"roll","v":1,"token":7}`, "invalid roll", nil},
+		{"resume without player", `{"type":"resume","v":1,"room":"ABC123"}`, "playerId is required", nil},
+		{"resume without room", `{"type":"resume","v":1,"playerId":"p1"}`, "room is required", nil},
+		{"blank chat", `{"type":"chat","v":1,"text":"   "}`, "text is required", nil},
+		{"long chat", `{"type":"chat","v":1,"text":"` + strings.Repeat("é", MaxChatLength+1) + `"}`, "longer than", nil},
+		{"unknown emote", `{"type":"react","v":1,"emote":"rocket"}`, `unknown emote "rocket"`, nil},
+		{"mute without player", `{"type":"mute","v":1,"muted":true}`, "playerId is required", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Decode([]byte(tt.in))
+			if err == nil {
+				t.Fatalf("Decode accepted %s as %T", tt.in, msg)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode error %q, want %q", err, tt.want)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("Decode error %v is not %v", err, tt.is)
+			}
+		})
+	}
+}
+
+func TestMarshalStampsEnvelope(t *testing.T) {
+	b, err := Marshal(&Ack{Ref: "r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != `{"type":"ack","v":1,"ref":"r1"}` {
+		t.Errorf("Marshal = %s", got)
+	}
+	if _, err := Marshal(&Roll{}); err == nil {
+		t.Error("Marshal accepted an inbound message")
+	}
+}
+
+func TestPeekID(t *testing.T) {
+	if got := PeekID([]byte(`{"type":"nope","id":"x1","bogus":true}`)); got != "x1" {
+		t.Errorf("PeekID = %q, want x1", got)
+	}
+	if got := PeekID([]byte(`not json`)); got != "" {
+		t.Errorf("PeekID of garbage = %q", got)
+	}
+}
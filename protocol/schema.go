@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Schema generates the JSON Schema (draft 2020-12) for every message. The
+// "Inbound" and "Outbound" definitions are unions of the message types.
+func Schema() ([]byte, error) {
+	defs := map[string]any{}
+	inbound := union(Inbound, defs, false)
+	outbound := union(Outbound, defs, true)
+	defs["Inbound"] = map[string]any{"oneOf": inbound}
+	defs["Outbound"] = map[string]any{"oneOf": outbound}
+
+	return json.MarshalIndent(map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "/protocol/schema.json",
+		"title":   "Monopoly WebSocket protocol",
+		"version": Version,
+		"$defs":   defs,
+	}, "", "  ")
+}
+
+// union adds a definition for each message to defs and returns refs to them.
+// Clients may omit "v", so it is only required on outbound messages.
+func union(msgs map[string]Message, defs map[string]any, requireV bool) []any {
+	names := make([]string, 0, len(msgs))
+	for name := range msgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	refs := make([]any, 0, len(names))
+	for _, name := range names {
+		t := reflect.TypeOf(msgs[name]).Elem()
+		s := structSchema(t)
+		props := s["properties"].(map[string]any)
+		props["type"] = map[string]any{"const": name}
+		props["v"] = map[string]any{"type": "integer", "minimum": 0, "maximum": Version}
+		if !requireV {
+			s["required"] = without(s["required"].([]string), "v")
+		}
+		s["additionalProperties"] = false
+		defs[t.Name()] = s
+		refs = append(refs, map[string]any{"$ref": "#/$defs/" + t.Name()})
+	}
+	return refs
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			embedded := structSchema(f.Type)
+			for k, v := range embedded["properties"].(map[string]any) {
+				props[k] = v
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]any{}
+}
+
+func without(list []string, drop string) []string {
+	out := list[:0:0]
+	for _, s := range list {
+		if s != drop {
+			out = append(out, s)
+		}
+	}
+	return out
+}
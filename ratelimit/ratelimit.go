@@ -0,0 +1,103 @@
+// Package ratelimit implements token-bucket throttling for single callers
+// (a Bucket) and for callers identified by a key such as an IP or playerId
+// (a Limiter).
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+/* ===== Bucket ===== */
+
+// Bucket refills at rate tokens per second up to burst tokens. Each allowed
+// call spends one token.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket.
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Allow spends a token if one is available. When it is not, it reports how
+// long until the next token arrives.
+func (b *Bucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+func (b *Bucket) idleSince() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+/* ===== Limiter ===== */
+
+// idleTTL is how long an unused key's bucket is kept before being swept.
+const idleTTL = 10 * time.Minute
+
+// Limiter keeps one Bucket per key.
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*Bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow spends a token from key's bucket.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	b := l.buckets[key]
+	if b == nil {
+		b = NewBucket(l.rate, l.burst)
+		l.buckets[key] = b
+	}
+	if time.Since(l.lastSweep) > time.Minute {
+		l.sweepLocked()
+	}
+	l.mu.Unlock()
+	return b.Allow()
+}
+
+// sweepLocked drops buckets that have been idle long enough to be full again.
+func (l *Limiter) sweepLocked() {
+	cutoff := time.Now().Add(-idleTTL)
+	for k, b := range l.buckets {
+		if b.idleSince().Before(cutoff) {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = time.Now()
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// rewind pretends the bucket was last used d ago.
+func rewind(b *Bucket, d time.Duration) {
+	b.mu.Lock()
+	b.last = b.last.Add(-d)
+	b.mu.Unlock()
+}
+
+func TestBucketBurst(t *testing.T) {
+	b := NewBucket(1, 3)
+	for i := range 3 {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatalf("call %d refused within the burst", i)
+		}
+	}
+	ok, wait := b.Allow()
+	if ok {
+		t.Fatal("call past the burst allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want up to 1s at 1 token/s", wait)
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		elapsed time.Duration
+		allowed int // calls allowed after draining, then waiting elapsed
+	}{
+		{"one token", 1, 3, 1100 * time.Millisecond, 1},
+		{"two tokens", 1, 3, 2100 * time.Millisecond, 2},
+		{"capped at burst", 1, 3, time.Hour, 3},
+		{"partial token", 2, 5, 300 * time.Millisecond, 0},
+		{"slow rate", 0.5, 5, 2100 * time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBucket(tt.rate, tt.burst)
+			for range tt.burst {
+				b.Allow()
+			}
+			rewind(b, tt.elapsed)
+			got := 0
+			for {
+				ok, _ := b.Allow()
+				if !ok {
+					break
+				}
+				got++
+			}
+			if got != tt.allowed {
+				t.Errorf("allowed %d calls, want %d", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBucketWait(t *testing.T) {
+	b := NewBucket(0.5, 1)
+	b.Allow()
+	rewind(b, time.Second) // half a token back
+	ok, wait := b.Allow()
+	if ok {
+		t.Fatal("allowed with half a token")
+	}
+	if wait < 900*time.Millisecond || wait > time.Second {
+		t.Errorf("wait = %v, want about 1s for the other half token", wait)
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	l := New(1, 2)
+	for range 2 {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatal("a refused within its burst")
+		}
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Error("a allowed past its burst")
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("b was throttled by a's bucket")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := New(1, 2)
+	l.Allow("idle")
+	l.Allow("busy")
+
+	l.mu.Lock()
+	rewind(l.buckets["idle"], idleTTL+time.Minute)
+	l.lastSweep = l.lastSweep.Add(-2 * time.Minute)
+	l.mu.Unlock()
+
+	l.Allow("busy") // sweeps
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("busy bucket was swept")
+	}
+}
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"monopoly/config"
+	"monopoly/dice"
+	"monopoly/errcode"
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+/* ===== Recordings ===== */
+
+// A recording is a room's event log saved when its game ends. It is
+// exported as JSON lines: a recordingHeader, then one roomEvent per line.
+
+const (
+	recordingFormat   = "monopoly-recording"
+	recordingVersion  = 1
+	maxRecordings     = 50       // completed games kept in memory
+	maxRecordingBytes = 16 << 20 // largest import accepted
+)
+
+type recordingHeader struct {
+	Format    string       `json:"format"`
+	Version   int          `json:"version"`
+	ID        string       `json:"id"`
+	Room      string       `json:"room"`
+	Name      string       `json:"name,omitempty"`
+	Started   time.Time    `json:"started"`
+	Ended     time.Time    `json:"ended,omitzero"` // zero while the game is live
+	Seed      *int64       `json:"seed,omitempty"` // seeded dice, once the game has ended
+	Fair      *dice.Record `json:"fair,omitempty"` // fair dice, revealed when the game ended
+	Rules     config.Rules `json:"rules"`
+	Events    int          `json:"events"`
+	Truncated bool         `json:"truncated,omitempty"` // the oldest events had already been dropped
+}
+
+type recording struct {
+	recordingHeader
+	events []roomEvent
+}
+
+// recordings holds completed games, oldest first, guarded by mu.
+var recordings []*recording
+
+var (
+	errNoRecording = errcode.New(errcode.NotFound, "No recording for that room.")
+	errReplayAuth  = errcode.New(errcode.Unauthorized, "session token for this room or admin token required")
+)
+
+// newRecording captures g's event log. fair is the dice reveal, only known
+// once the game is over. The dice seed predicts every later roll, so a live
+// game (zero ended) is exported without it.
+func newRecording(name string, g *game.Room, l *eventLog, fair *dice.Record, ended time.Time) *recording {
+	rec := &recording{
+		recordingHeader: recordingHeader{
+			Format:  recordingFormat,
+			Version: recordingVersion,
+			ID:      g.ID + "-" + strconv.FormatInt(g.Started.UnixMilli(), 36),
+			Room:    g.ID,
+			Name:    name,
+			Started: g.Started,
+			Ended:   ended,
+			Fair:    fair,
+			Rules:   cfg.Rules,
+		},
+		events: l.since(0),
+	}
+	if d, ok := g.Dice().(interface{ Seed() int64 }); ok && !ended.IsZero() {
+		seed := d.Seed()
+		rec.Seed = &seed
+	}
+	rec.Events = len(rec.events)
+	rec.Truncated = rec.Events > 0 && rec.events[0].Seq > 1
+	return rec
+}
+
+// played reports whether anyone moved, so empty visits are not kept.
+func (rec *recording) played() bool {
+	for _, e := range rec.events {
+		if e.Type == "move" {
+			return true
+		}
+	}
+	return false
+}
+
+// keepRecordingLocked stores rec, replacing an earlier import of the same
+// game. Callers hold mu.
+func keepRecordingLocked(rec *recording) {
+	for i, old := range recordings {
+		if old.ID == rec.ID {
+			recordings = append(recordings[:i], recordings[i+1:]...)
+			break
+		}
+	}
+	recordings = append(recordings, rec)
+	if len(recordings) > maxRecordings {
+		recordings = recordings[len(recordings)-maxRecordings:]
+	}
+}
+
+func findRecording(id string) *recording {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, rec := range recordings {
+		if rec.ID == id {
+			return rec
+		}
+	}
+	return nil
+}
+
+// roomRecording is the room's live game, or else its latest completed one.
+func roomRecording(room string) *recording {
+	mu.Lock()
+	g, l := games[room], eventLogs[room]
+	var name string
+	if m := lobby[room]; m != nil {
+		name = m.Name
+	}
+	if replays[room] == nil && g != nil && l != nil {
+		mu.Unlock()
+		return newRecording(name, g, l, nil, time.Time{})
+	}
+	defer mu.Unlock()
+	for i := len(recordings) - 1; i >= 0; i-- {
+		if recordings[i].Room == room {
+			return recordings[i]
+		}
+	}
+	return nil
+}
+
+func (rec *recording) writeTo(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(rec.recordingHeader); err != nil {
+		return err
+	}
+	for _, e := range rec.events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecording parses an exported recording. Every event must be an
+// outbound protocol message, in sequence order.
+func readRecording(r io.Reader) (*recording, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	if !sc.Scan() {
+		return nil, errors.New("empty recording")
+	}
+	rec := &recording{}
+	if err := json.Unmarshal(sc.Bytes(), &rec.recordingHeader); err != nil {
+		return nil, fmt.Errorf("header: %v", err)
+	}
+	if rec.Format != recordingFormat || rec.Version != recordingVersion {
+		return nil, fmt.Errorf("not a %s v%d file", recordingFormat, recordingVersion)
+	}
+	if rec.Room == "" {
+		return nil, errors.New("header: room is required")
+	}
+	for line := 2; sc.Scan(); line++ {
+		var e roomEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		var env protocol.Envelope
+		if err := json.Unmarshal(e.Message, &env); err != nil || protocol.Outbound[env.Type] == nil || env.Type != e.Type {
+			return nil, fmt.Errorf("line %d: not an outbound message", line)
+		}
+		if n := len(rec.events); n > 0 && e.Seq <= rec.events[n-1].Seq {
+			return nil, fmt.Errorf("line %d: events out of order", line)
+		}
+		rec.events = append(rec.events, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(rec.events) == 0 {
+		return nil, errors.New("recording has no events")
+	}
+	rec.Events = len(rec.events)
+	if rec.ID == "" {
+		rec.ID = rec.Room + "-" + strconv.FormatInt(rec.Started.UnixMilli(), 36)
+	}
+	return rec, nil
+}
+
+func writeRecording(w http.ResponseWriter, rec *recording) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="monopoly-%s.jsonl"`, rec.ID))
+	_ = rec.writeTo(w)
+}
+
+/* ===== Replays ===== */
+
+const (
+	maxReplayGap   = 3 * time.Second // long pauses in the game are cut short
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 16
+)
+
+// replay re-emits a recording into a read-only room. Spectators join it by
+// code like any room; it starts when the first one arrives.
+type replay struct {
+	rec *recording
+
+	// guarded by mu
+	speed   float64
+	pos     int             // events re-emitted so far
+	players []byte          // the latest roster, for spectators arriving late
+	state   *protocol.State // the latest state, with the moves since applied
+
+	started   chan struct{}
+	startOnce sync.Once
+	stop      chan struct{} // closed when the room goes away
+}
+
+// replays holds the running replays by room code, guarded by mu.
+var replays = make(map[string]*replay)
+
+func replaySpeed(s float64) (float64, error) {
+	if s == 0 {
+		return 1, nil
+	}
+	if s < minReplaySpeed || s > maxReplaySpeed {
+		return 0, errcode.New(errcode.BadMessage, fmt.Sprintf("speed must be between %g and %g", minReplaySpeed, float64(maxReplaySpeed)))
+	}
+	return s, nil
+}
+
+// startReplay opens a private room that plays rec back at speed.
+func startReplay(rec *recording, speed float64) roomMeta {
+	name := rec.Name
+	if name == "" {
+		name = "Room " + rec.Room
+	}
+	m := createRoom("Replay: "+name, false, "")
+	rp := &replay{rec: rec, speed: speed, started: make(chan struct{}), stop: make(chan struct{})}
+	mu.Lock()
+	lobby[m.ID].Replay = true
+	games[m.ID] = game.NewRoom(m.ID, nil)
+	eventLogs[m.ID] = &eventLog{}
+	replays[m.ID] = rp
+	mu.Unlock()
+	roomLog(m.ID).Info("replay created", "recording", rec.ID, "speed", speed)
+	go runReplay(m.ID, rp)
+	m.Replay = true
+	return m
+}
+
+// endReplayLocked stops room's replay, if it has one. Callers hold mu.
+func endReplayLocked(room string) {
+	if rp := replays[room]; rp != nil {
+		close(rp.stop)
+		delete(replays, room)
+	}
+}
+
+func runReplay(room string, rp *replay) {
+	select {
+	case <-rp.started:
+	case <-rp.stop:
+		return
+	}
+	var prev time.Time
+	for _, e := range rp.rec.events {
+		if !prev.IsZero() {
+			mu.Lock()
+			wait := time.Duration(float64(min(e.Time.Sub(prev), maxReplayGap)) / rp.speed)
+			mu.Unlock()
+			select {
+			case <-time.After(wait):
+			case <-rp.stop:
+				return
+			}
+		}
+		prev = e.Time
+
+		mu.Lock()
+		rp.pos++
+		rp.applyLocked(e)
+		mu.Unlock()
+		broadcastRaw(room, e.Type, e.Message)
+	}
+	broadcast(room, &protocol.Event{Text: "Replay finished."})
+	mu.Lock()
+	status := rp.statusLocked()
+	mu.Unlock()
+	fanout(room, status, nil)
+	roomLog(room).Info("replay finished", "recording", rp.rec.ID)
+}
+
+// applyLocked tracks the board as of event e for late spectators.
+func (rp *replay) applyLocked(e roomEvent) {
+	switch e.Type {
+	case "players":
+		rp.players = e.Message
+	case "state":
+		var s protocol.State
+		if json.Unmarshal(e.Message, &s) == nil {
+			rp.state = &s
+		}
+	case "move":
+		var m protocol.Move
+		if rp.state != nil && json.Unmarshal(e.Message, &m) == nil {
+			if rp.state.Positions == nil {
+				rp.state.Positions = make(map[string]int)
+			}
+			rp.state.Positions[m.PlayerID] = m.To
+		}
+	}
+}
+
+func (rp *replay) statusLocked() *protocol.Replay {
+	return &protocol.Replay{
+		Recording: rp.rec.ID,
+		Speed:     rp.speed,
+		Position:  rp.pos,
+		Total:     len(rp.rec.events),
+		Finished:  rp.pos == len(rp.rec.events),
+	}
+}
+
+// replayCatchUp is what a spectator arriving now needs to see the board:
+// the latest roster and state re-emitted so far. It is nil for other rooms.
+func replayCatchUp(room string) [][]byte {
+	mu.Lock()
+	defer mu.Unlock()
+	rp := replays[room]
+	if rp == nil {
+		return nil
+	}
+	out := [][]byte{}
+	if rp.players != nil {
+		out = append(out, rp.players)
+	}
+	if rp.state != nil {
+		if b, err := protocol.Marshal(rp.state); err == nil {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// watchReplay adds client to a replay room as a spectator. Spectators are
+// never seated and get no session token.
+func watchReplay(client *Client, in *protocol.Resume) error {
+	mu.Lock()
+	rp := replays[in.Room]
+	mu.Unlock()
+	if rp == nil {
+		return errcode.New(errcode.NotFound, "This replay has ended.")
+	}
+	client.ID = in.PlayerID
+	client.Name = in.Name
+	client.Room = in.Room
+	client.spectator = true
+	if !addToRoom(client.Room, client) {
+		return errcode.New(errcode.RoomFull, fmt.Sprintf("Room is full (%d players max).", maxPlayers))
+	}
+	rp.startOnce.Do(func() { close(rp.started) })
+	clientLog(client).Info("watching replay", "recording", rp.rec.ID)
+	sendReplayCatchUp(client, rp)
+	return nil
+}
+
+func sendReplayCatchUp(c *Client, rp *replay) {
+	mu.Lock()
+	status := rp.statusLocked()
+	mu.Unlock()
+	c.send(status)
+	for _, b := range replayCatchUp(c.Room) {
+		c.writeRaw(b)
+	}
+}
+
+// spectate handles the messages a spectator sends. Anything that would
+// change the game is refused; handled is false for messages the normal
+// dispatch can take (ping, leave, subscribeLogs).
+func spectate(c *Client, msg protocol.Message) (handled bool, err error) {
+	switch msg.(type) {
+	case *protocol.Who, *protocol.Sync:
+		mu.Lock()
+		rp := replays[c.Room]
+		mu.Unlock()
+		if rp == nil {
+			return true, errcode.New(errcode.NotFound, "This replay has ended.")
+		}
+		sendReplayCatchUp(c, rp)
+		return true, nil
+	case *protocol.Ping, *protocol.Leave, *protocol.SubscribeLogs:
+		return false, nil
+	}
+	return true, errcode.New(errcode.NotSeated, "Replays are read-only.")
+}
+
+/* ===== REST: recordings and replays ===== */
+
+// roomRecordingHTTP exports the room's game, live or last completed.
+func roomRecordingHTTP(w http.ResponseWriter, r *http.Request) {
+	g, ok := readableRoom(w, r)
+	if !ok {
+		return
+	}
+	rec := roomRecording(g.ID)
+	if rec == nil {
+		writeError(w, errorMsg("", errNoRecording))
+		return
+	}
+	writeRecording(w, rec)
+}
+
+// roomReplayHTTP starts a replay of the room's last completed game for one
+// of its players (or an admin) and answers 201 with the replay room's join
+// code and a token that controls the replay's speed.
+func roomReplayHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errorMsg("", errMethodNotAllowed))
+		return
+	}
+	if ok, retry := allow(ipLimit, "ip", remoteIP(r)); !ok {
+		writeError(w, rateLimitedMsg("", retry))
+		return
+	}
+	room := r.PathValue("room")
+	host, ok := replayCaller(r, room)
+	if !ok {
+		writeError(w, errorMsg("", errReplayAuth))
+		return
+	}
+	rec := roomRecording(room)
+	if rec == nil || rec.Ended.IsZero() {
+		writeError(w, errorMsg("", errcode.New(errcode.NotFound, "No finished game to replay in that room.")))
+		return
+	}
+	replayHTTP(w, r, rec, host)
+}
+
+// replaySpeedHTTP changes the speed of a running replay from {"speed"}. It
+// needs the token the replay was created with, or the admin token.
+func replaySpeedHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errorMsg("", errMethodNotAllowed))
+		return
+	}
+	room := r.PathValue("room")
+	if _, ok := replayCaller(r, room); !ok {
+		writeError(w, errorMsg("", errReplayAuth))
+		return
+	}
+	var req struct {
+		Speed float64 `json:"speed"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Speed == 0 {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "speed is required")))
+		return
+	}
+	speed, err := replaySpeed(req.Speed)
+	if err != nil {
+		writeError(w, errorMsg("", err))
+		return
+	}
+	mu.Lock()
+	rp := replays[room]
+	var status *protocol.Replay
+	if rp != nil {
+		rp.speed = speed
+		status = rp.statusLocked()
+	}
+	mu.Unlock()
+	if rp == nil {
+		writeError(w, errorMsg("", errcode.New(errcode.NotFound, "That room is not a replay.")))
+		return
+	}
+	fanout(room, status, nil)
+	writeJSON(w, status)
+}
+
+// replayCaller checks that r may start or steer replays for room: it needs
+// a session token for room, which names the player, or the admin token.
+func replayCaller(r *http.Request, room string) (player string, ok bool) {
+	if isAdmin(r) {
+		return "", true
+	}
+	c, err := sessions.Parse(bearerToken(r))
+	if err != nil || c.Room != room {
+		return "", false
+	}
+	if m, listed := getRoomMeta(room); listed && m.Kicked[c.PlayerID] {
+		return "", false
+	}
+	return c.PlayerID, true
+}
+
+// replayHTTP starts rec for host, whose token for the new room controls it.
+func replayHTTP(w http.ResponseWriter, r *http.Request, rec *recording, host string) {
+	var req struct {
+		Speed float64 `json:"speed"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "bad json")))
+			return
+		}
+	}
+	speed, err := replaySpeed(req.Speed)
+	if err != nil {
+		writeError(w, errorMsg("", err))
+		return
+	}
+	m := startReplay(rec, speed)
+	w.Header().Set("Location", "/rooms/"+m.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]any{"code": m.ID, "recording": rec.ID, "speed": speed, "token": sessions.Issue(m.ID, host)})
+}
+
+func adminRecordingsHTTP(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	out := make([]recordingHeader, 0, len(recordings))
+	for i := len(recordings) - 1; i >= 0; i-- {
+		out = append(out, recordings[i].recordingHeader)
+	}
+	mu.Unlock()
+	writeJSON(w, out)
+}
+
+func adminRecordingHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := findRecording(r.PathValue("id"))
+	if rec == nil {
+		writeError(w, errorMsg("", errNoRecording))
+		return
+	}
+	writeRecording(w, rec)
+}
+
+// adminImportRecordingHTTP stores an exported recording so it can be
+// replayed here.
+func adminImportRecordingHTTP(w http.ResponseWriter, r *http.Request) {
+	rec, err := readRecording(http.MaxBytesReader(w, r.Body, maxRecordingBytes))
+	if err != nil {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, err.Error())))
+		return
+	}
+	mu.Lock()
+	keepRecordingLocked(rec)
+	mu.Unlock()
+	roomLog(rec.Room).Info("recording imported", "recording", rec.ID, "events", rec.Events)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, rec.recordingHeader)
+}
+
+func adminReplayHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := findRecording(r.PathValue("id"))
+	if rec == nil {
+		writeError(w, errorMsg("", errNoRecording))
+		return
+	}
+	replayHTTP(w, r, rec, "")
+}
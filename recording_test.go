@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"monopoly/dice"
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+// testRecording is a finished game in room where player a moves n times.
+func testRecording(t *testing.T, room string, n int) *recording {
+	t.Helper()
+	start := time.Now().Add(-time.Hour)
+	rec := &recording{recordingHeader: recordingHeader{
+		Format: recordingFormat, Version: recordingVersion, ID: room + "-test",
+		Room: room, Started: start, Ended: start.Add(time.Minute),
+	}}
+	add := func(msg protocol.Message) {
+		b, err := protocol.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq := uint64(len(rec.events) + 1)
+		rec.events = append(rec.events, roomEvent{Seq: seq, Version: seq, Time: start.Add(time.Duration(seq) * time.Millisecond), Type: protocol.Type(msg), Message: b})
+	}
+	add(&protocol.Players{List: []game.Player{{ID: "a", Name: "Alice"}}})
+	for i := range n {
+		add(&protocol.Move{PlayerID: "a", From: i, To: i + 1, Dice: [2]int{1, 0}})
+	}
+	rec.Events = len(rec.events)
+	return rec
+}
+
+// keepTestRecording stores rec until t ends.
+func keepTestRecording(t *testing.T, rec *recording) {
+	mu.Lock()
+	keepRecordingLocked(rec)
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		for i, r := range recordings {
+			if r == rec {
+				recordings = append(recordings[:i], recordings[i+1:]...)
+				break
+			}
+		}
+		mu.Unlock()
+	})
+}
+
+func TestReadRecording(t *testing.T) {
+	var buf bytes.Buffer
+	want := testRecording(t, "REC001", 3)
+	if err := want.writeTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := readRecording(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+	if rec.ID != want.ID || rec.Events != 4 || len(rec.events) != 4 || string(rec.events[3].Message) != string(want.events[3].Message) {
+		t.Errorf("round trip = %+v", rec.recordingHeader)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	header, events := lines[0], lines[1:]
+	join := func(ls ...string) string { return strings.Join(ls, "\n") }
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", "empty recording"},
+		{"bad header", "{", "header"},
+		{"other format", strings.Replace(header, recordingFormat, "chess-recording", 1), "not a monopoly-recording v1 file"},
+		{"newer version", strings.Replace(header, `"version":1`, `"version":2`, 1), "not a monopoly-recording v1 file"},
+		{"no room", strings.Replace(header, `"room":"REC001"`, `"room":""`, 1), "room is required"},
+		{"no events", header, "no events"},
+		{"malformed event", join(header, events[0], "{nope"), "line 3"},
+		{"inbound message", join(header, strings.Replace(events[0], `"type":"players"`, `"type":"roll"`, 2)), "line 2: not an outbound message"},
+		{"type mismatch", join(header, strings.Replace(events[0], `"type":"players","message"`, `"type":"move","message"`, 1)), "line 2: not an outbound message"},
+		{"out of order", join(header, events[1], events[0]), "line 3: events out of order"},
+		{"repeated", join(header, events[0], events[0]), "line 3: events out of order"},
+	}
+	for _, tt := range tests {
+		_, err := readRecording(strings.NewReader(tt.in))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want an error mentioning %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRecordingSeed(t *testing.T) {
+	g := game.NewRoom("REC002", dice.NewSeeded(42))
+	var l eventLog
+	l.append(0, "players", []byte(`{"type":"players","list":[]}`))
+
+	live := newRecording("", g, &l, nil, time.Time{})
+	ended := newRecording("", g, &l, nil, time.Now())
+	if live.Seed != nil {
+		t.Error("a live game was exported with its dice seed")
+	}
+	if ended.Seed == nil || *ended.Seed != 42 {
+		t.Errorf("finished game seed = %v, want 42", ended.Seed)
+	}
+
+	var buf bytes.Buffer
+	if err := live.writeTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.SplitN(buf.String(), "\n", 2)[0], "seed") {
+		t.Errorf("live export header %q carries a seed", buf.String())
+	}
+}
+
+// replayMessages collects what c receives until the replay finishes.
+func replayMessages(t *testing.T, c *Client) []json.RawMessage {
+	t.Helper()
+	var out []json.RawMessage
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case b := <-c.out:
+			out = append(out, b)
+			var st protocol.Replay
+			if json.Unmarshal(b, &st) == nil && st.Type == "replay" && st.Finished {
+				return out
+			}
+		case <-deadline:
+			t.Fatalf("replay not finished after 5s; got %d messages", len(out))
+		}
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	rec := testRecording(t, "REC003", 20)
+	m := startReplay(rec, maxReplaySpeed)
+	t.Cleanup(func() { _ = closeRoom(m.ID) })
+	c := newTestConn(t)
+	if _, err := dispatch(c, &protocol.Resume{PlayerID: "viewer", Name: "Viewer", Room: m.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	var moves []int
+	for _, b := range replayMessages(t, c) {
+		var mv protocol.Move
+		if json.Unmarshal(b, &mv) == nil && mv.Type == "move" {
+			moves = append(moves, mv.To)
+		}
+	}
+	if len(moves) != 20 {
+		t.Fatalf("spectator saw %d moves, want 20", len(moves))
+	}
+	for i, to := range moves {
+		if to != i+1 {
+			t.Fatalf("moves replayed as %v, want 1..20 in order", moves)
+		}
+	}
+	if _, err := dispatch(c, &protocol.Roll{}); err == nil {
+		t.Error("a spectator rolled in a replay")
+	}
+}
+
+func TestClosedReplayNotRecorded(t *testing.T) {
+	rec := testRecording(t, "REC004", 3)
+	m := startReplay(rec, maxReplaySpeed)
+	c := newClient(nil, "192.0.2.1")
+	if _, err := dispatch(c, &protocol.Resume{PlayerID: "viewer", Name: "Viewer", Room: m.ID}); err != nil {
+		t.Fatal(err)
+	}
+	replayMessages(t, c)
+
+	if err := closeRoom(m.ID); err != nil {
+		t.Fatal(err)
+	}
+	onClose(c) // as the socket handler would
+	if getGame(m.ID) != nil {
+		t.Error("closed replay room still has a game")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, r := range recordings {
+		if r.Room == m.ID {
+			t.Errorf("the replay was recorded as a game: %+v", r.recordingHeader)
+		}
+	}
+}
+
+func TestReplayAuth(t *testing.T) {
+	old := cfg.AdminToken
+	cfg.AdminToken = "adm1n"
+	t.Cleanup(func() { cfg.AdminToken = old })
+	srv := startTestServer(t)
+	room := newLobbyRoom(t, "")
+	keepTestRecording(t, testRecording(t, room, 3))
+
+	post := func(path, token, body string) (*http.Response, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		var out map[string]any
+		_ = json.NewDecoder(res.Body).Decode(&out)
+		return res, out
+	}
+
+	replay := "/rooms/" + room + "/replay"
+	for _, tt := range []struct {
+		name, token string
+	}{
+		{"no token", ""},
+		{"bad token", "nope"},
+		{"other room's token", sessions.Issue("ELSEWH", "a")},
+	} {
+		if res, _ := post(replay, tt.token, ""); res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("start replay, %s: %d, want 401", tt.name, res.StatusCode)
+		}
+	}
+	res, body := post(replay, sessions.Issue(room, "a"), "")
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("player starting a replay: %d %v", res.StatusCode, body)
+	}
+	code, _ := body["code"].(string)
+	token, _ := body["token"].(string)
+	t.Cleanup(func() { _ = closeRoom(code) })
+
+	speed := "/rooms/" + code + "/replay/speed"
+	for _, tt := range []struct {
+		name, token string
+		want        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"source room's token", sessions.Issue(room, "a"), http.StatusUnauthorized},
+		{"replay token", token, http.StatusOK},
+		{"admin token", "adm1n", http.StatusOK},
+	} {
+		if res, _ := post(speed, tt.token, `{"speed":2}`); res.StatusCode != tt.want {
+			t.Errorf("set speed, %s: %d, want %d", tt.name, res.StatusCode, tt.want)
+		}
+	}
+	res, body = post(replay, "adm1n", "")
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("admin starting a replay: %d %v", res.StatusCode, body)
+	}
+	adminCode, _ := body["code"].(string)
+	t.Cleanup(func() { _ = closeRoom(adminCode) })
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"monopoly/errcode"
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+/* ===== REST: /rooms/{room}/... ===== */
+
+// These read-only views serve integrations that don't speak the WebSocket
+// protocol. Responses carry an ETag derived from the room version, so a
+// poller sending If-None-Match gets 304 until something changes.
+
+// readableRoom resolves {room} for a GET. Password-protected rooms also
+// need the password (X-Room-Password) or a session token for the room.
+func readableRoom(w http.ResponseWriter, r *http.Request) (*game.Room, bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, errorMsg("", errMethodNotAllowed))
+		return nil, false
+	}
+	return roomAccess(w, r)
+}
+
+// roomAccess is readableRoom for any method.
+func roomAccess(w http.ResponseWriter, r *http.Request) (*game.Room, bool) {
+	room := r.PathValue("room")
+	meta, ok := getRoomMeta(room)
+	g := getGame(room)
+	if !ok && g == nil {
+		writeError(w, errorMsg("", errNoRoomCode))
+		return nil, false
+	}
+	if meta.hasPassword() && !meta.checkPassword(r.Header.Get("X-Room-Password")) {
+		if c, err := sessions.Parse(bearerToken(r)); err != nil || c.Room != room || meta.Kicked[c.PlayerID] {
+			writeError(w, errorMsg("", errcode.New(errcode.Unauthorized, "room password or session token required")))
+			return nil, false
+		}
+	}
+	if g == nil {
+		// Created but nobody has joined yet
+		g = game.NewRoom(room, nil)
+		g.Started = meta.Created
+	}
+	return g, true
+}
+
+// roomETag identifies a room state. Started tells apart successive games in
+// the same room, whose versions both count up from zero.
+func roomETag(g *game.Room, version uint64) string {
+	return fmt.Sprintf(`"%s.%d"`, strconv.FormatInt(g.Started.UnixMilli(), 36), version)
+}
+
+// notModified sets the ETag and answers 304 when the caller already has it.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+func writeMessage(w http.ResponseWriter, msg protocol.Message) {
+	b, err := protocol.Marshal(msg)
+	if err != nil {
+		writeError(w, errorMsg("", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// roomStateHTTP returns the same State message WebSocket clients get on
+// sync.
+func roomStateHTTP(w http.ResponseWriter, r *http.Request) {
+	g, ok := readableRoom(w, r)
+	if !ok {
+		return
+	}
+	v := g.View()
+	if notModified(w, r, roomETag(g, v.Version)) {
+		return
+	}
+	writeMessage(w, stateMsg(v))
+}
+
+// roomPlayerHTTP describes one seated player.
+func roomPlayerHTTP(w http.ResponseWriter, r *http.Request) {
+	g, ok := readableRoom(w, r)
+	if !ok {
+		return
+	}
+	v := g.View()
+	pid := r.PathValue("pid")
+	pos, seated := v.Positions[pid]
+	if !seated {
+		writeError(w, errorMsg("", game.ErrNotSeated))
+		return
+	}
+	if notModified(w, r, roomETag(g, v.Version)) {
+		return
+	}
+	p, _ := g.Player(pid)
+	writeJSON(w, map[string]any{
+		"id":       p.ID,
+		"name":     p.Name,
+		"position": pos,
+		"tile":     game.Board[pos].Name,
+		"hasTurn":  v.Turn == pid,
+		"version":  v.Version,
+	})
+}
+
+// roomBoardHTTP lists the tiles with the players standing on each.
+func roomBoardHTTP(w http.ResponseWriter, r *http.Request) {
+	g, ok := readableRoom(w, r)
+	if !ok {
+		return
+	}
+	v := g.View()
+	if notModified(w, r, roomETag(g, v.Version)) {
+		return
+	}
+	type tile struct {
+		game.Tile
+		Players []string `json:"players"`
+	}
+	tiles := make([]tile, game.BoardSize)
+	for i, t := range game.Board {
+		tiles[i] = tile{Tile: t, Players: []string{}}
+	}
+	for _, p := range v.Players {
+		pos := v.Positions[p.ID]
+		tiles[pos].Players = append(tiles[pos].Players, p.ID)
+	}
+	writeJSON(w, map[string]any{"version": v.Version, "tiles": tiles})
+}
+
+// roomEventsHTTP returns the room's recent broadcasts made after room
+// version ?since=<version>. Several broadcasts can share a version, so
+// ?after=<seq> instead pages by the events' sequence numbers. The ETag is
+// the last sequence number, so an idle room polls as 304.
+func roomEventsHTTP(w http.ResponseWriter, r *http.Request) {
+	g, ok := readableRoom(w, r)
+	if !ok {
+		return
+	}
+	since, err1 := queryUint(r, "since")
+	after, err2 := queryUint(r, "after")
+	if err := errors.Join(err1, err2); err != nil {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, err.Error())))
+		return
+	}
+	events := []roomEvent{}
+	var last uint64
+	if l := getEventLog(g.ID); l != nil {
+		for _, e := range l.since(after) {
+			if !r.URL.Query().Has("since") || e.Version > since {
+				events = append(events, e)
+			}
+		}
+		last = l.last()
+	}
+	if notModified(w, r, fmt.Sprintf(`"%s.e%d"`, strconv.FormatInt(g.Started.UnixMilli(), 36), last)) {
+		return
+	}
+	writeJSON(w, map[string]any{"version": g.Version(), "last": last, "events": events})
+}
+
+// queryUint parses the optional query parameter name, 0 if absent.
+func queryUint(r *http.Request, name string) (uint64, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"monopoly/protocol"
+)
+
+// apiGet requests path with the given headers and decodes a JSON body into v
+// when v is not nil.
+func apiGet(t *testing.T, url string, header map[string]string, v any) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	for k, val := range header {
+		req.Header.Set(k, val)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if v != nil && res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res
+}
+
+func TestRoomStateETag(t *testing.T) {
+	srv := startTestServer(t)
+	room := newLobbyRoom(t, "")
+	c := newTestConn(t)
+	tok := seat(t, c, room, "p1", "")
+	url := srv.URL + "/rooms/" + room
+
+	for _, path := range []string{"/state", "/board", "/players/p1"} {
+		res := apiGet(t, url+path, nil, nil)
+		etag := res.Header.Get("ETag")
+		if res.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("GET %s = %d with ETag %q", path, res.StatusCode, etag)
+		}
+		if res := apiGet(t, url+path, map[string]string{"If-None-Match": etag}, nil); res.StatusCode != http.StatusNotModified {
+			t.Errorf("GET %s with its ETag = %d, want 304", path, res.StatusCode)
+		}
+	}
+
+	etag := apiGet(t, url+"/state", nil, nil).Header.Get("ETag")
+	if _, err := dispatch(c, &protocol.Roll{Token: tok}); err != nil {
+		t.Fatal(err)
+	}
+	res := apiGet(t, url+"/state", map[string]string{"If-None-Match": etag}, nil)
+	if res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Errorf("after a roll: %d with ETag %s, want 200 and a new ETag", res.StatusCode, res.Header.Get("ETag"))
+	}
+
+	if res := apiGet(t, url+"/players/nobody", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unseated player = %d, want 404", res.StatusCode)
+	}
+	if res := apiGet(t, srv.URL+"/rooms/NOSUCH/state", nil, nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown room = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestRoomAPIPassword(t *testing.T) {
+	srv := startTestServer(t)
+	room := newLobbyRoom(t, "hunter2")
+	tok := seat(t, newTestConn(t), room, "p1", "hunter2")
+	url := srv.URL + "/rooms/" + room + "/state"
+
+	for _, tt := range []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{"nothing", nil, http.StatusUnauthorized},
+		{"wrong password", map[string]string{"X-Room-Password": "hunter3"}, http.StatusUnauthorized},
+		{"password", map[string]string{"X-Room-Password": "hunter2"}, http.StatusOK},
+		{"session token", map[string]string{"Authorization": "Bearer " + tok}, http.StatusOK},
+		{"bad token", map[string]string{"Authorization": "Bearer " + tok + "x"}, http.StatusUnauthorized},
+	} {
+		if res := apiGet(t, url, tt.header, nil); res.StatusCode != tt.want {
+			t.Errorf("%s: %d, want %d", tt.name, res.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestRoomEventsFilter(t *testing.T) {
+	srv := startTestServer(t)
+	room := newLobbyRoom(t, "")
+	c := newTestConn(t)
+	tok := seat(t, c, room, "p1", "")
+	for range 3 {
+		if _, err := dispatch(c, &protocol.Roll{Token: tok}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	url := srv.URL + "/rooms/" + room + "/events"
+
+	type page struct {
+		Version uint64      `json:"version"`
+		Last    uint64      `json:"last"`
+		Events  []roomEvent `json:"events"`
+	}
+	var all page
+	res := apiGet(t, url, nil, &all)
+	if res.StatusCode != http.StatusOK || len(all.Events) == 0 || all.Last != all.Events[len(all.Events)-1].Seq {
+		t.Fatalf("GET events = %d, %+v", res.StatusCode, all)
+	}
+	if n := len(getEventLog(room).since(0)); len(all.Events) != n {
+		t.Errorf("no filter returned %d events, want all %d", len(all.Events), n)
+	}
+
+	var since page
+	apiGet(t, url+"?since=1", nil, &since)
+	for _, e := range since.Events {
+		if e.Version <= 1 {
+			t.Errorf("since=1 returned an event at version %d", e.Version)
+		}
+	}
+	if len(since.Events) == 0 || len(since.Events) >= len(all.Events) {
+		t.Errorf("since=1 returned %d of %d events", len(since.Events), len(all.Events))
+	}
+
+	var after page
+	mid := all.Events[len(all.Events)/2].Seq
+	apiGet(t, url+"?after="+strconv.FormatUint(mid, 10), nil, &after)
+	if s := seqs(after.Events); len(s) != int(all.Last-mid) || !consecutive(s, mid+1) {
+		t.Errorf("after=%d returned %v", mid, s)
+	}
+
+	etag := res.Header.Get("ETag")
+	if res := apiGet(t, url+"?after="+strconv.FormatUint(all.Last, 10), map[string]string{"If-None-Match": etag}, nil); res.StatusCode != http.StatusNotModified {
+		t.Errorf("idle poll = %d, want 304", res.StatusCode)
+	}
+	for _, q := range []string{"?since=-1", "?after=x"} {
+		if res := apiGet(t, url+q, nil, nil); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", q, res.StatusCode)
+		}
+	}
+}
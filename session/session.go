@@ -0,0 +1,98 @@
+// Package session issues and checks the signed tokens that bind a client to
+// the seat (room + playerID) it resumed into.
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid   = errors.New("invalid session token")
+	ErrExpired   = errors.New("session token expired")
+	ErrWrongSeat = errors.New("session token does not match this seat")
+)
+
+// Claims is what a token vouches for.
+type Claims struct {
+	Room     string
+	PlayerID string
+	Expires  time.Time
+}
+
+// Signer issues and verifies HMAC-SHA256 signed tokens. A token is
+// base64url(room \x00 playerID \x00 expiryUnix) "." base64url(mac).
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewSigner signs with key. An empty key gets a random one, which means
+// tokens do not survive a restart.
+func NewSigner(key []byte, ttl time.Duration) *Signer {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		_, _ = rand.Read(key)
+	}
+	return &Signer{key: key, ttl: ttl}
+}
+
+// Issue returns a token for the seat.
+func (s *Signer) Issue(room, playerID string) string {
+	exp := time.Now().Add(s.ttl).Unix()
+	payload := room + "\x00" + playerID + "\x00" + strconv.FormatInt(exp, 10)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(s.mac(payload))
+}
+
+// Parse checks the token's signature and expiry and returns its claims.
+func (s *Signer) Parse(token string) (Claims, error) {
+	enc := base64.RawURLEncoding
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalid
+	}
+	payload, err1 := enc.DecodeString(p)
+	mac, err2 := enc.DecodeString(sig)
+	if err1 != nil || err2 != nil || !hmac.Equal(mac, s.mac(string(payload))) {
+		return Claims{}, ErrInvalid
+	}
+
+	parts := strings.Split(string(payload), "\x00")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalid
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return Claims{}, ErrInvalid
+	}
+	c := Claims{Room: parts[0], PlayerID: parts[1], Expires: time.Unix(exp, 0)}
+	if time.Now().After(c.Expires) {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+// Verify checks that token is valid and was issued for room and playerID.
+func (s *Signer) Verify(token, room, playerID string) error {
+	c, err := s.Parse(token)
+	if err != nil {
+		return err
+	}
+	if c.Room != room || c.PlayerID != playerID {
+		return ErrWrongSeat
+	}
+	return nil
+}
+
+func (s *Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,68 @@
+package session
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func TestIssueVerify(t *testing.T) {
+	s := NewSigner(key, time.Hour)
+	tok := s.Issue("ABC123", "p1")
+	c, err := s.Parse(tok)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if c.Room != "ABC123" || c.PlayerID != "p1" {
+		t.Errorf("claims = %+v, want room ABC123 player p1", c)
+	}
+	if d := time.Until(c.Expires); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("expires in %v, want about an hour", d)
+	}
+	if err := NewSigner(key, time.Hour).Verify(tok, "ABC123", "p1"); err != nil {
+		t.Errorf("a signer with the same key rejected the token: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := NewSigner(key, time.Hour)
+	valid := s.Issue("ABC123", "p1")
+	payload, mac, _ := strings.Cut(valid, ".")
+	enc := base64.RawURLEncoding
+
+	// forge signs a malformed body with the right key.
+	forge := func(body string) string {
+		return enc.EncodeToString([]byte(body)) + "." + enc.EncodeToString(s.mac(body))
+	}
+	tests := []struct {
+		name         string
+		token        string
+		room, player string
+		want         error
+	}{
+		{"valid", valid, "ABC123", "p1", nil},
+		{"wrong room", valid, "XYZ789", "p1", ErrWrongSeat},
+		{"wrong player", valid, "ABC123", "p2", ErrWrongSeat},
+		{"expired", NewSigner(key, -time.Minute).Issue("ABC123", "p1"), "ABC123", "p1", ErrExpired},
+		{"other key", NewSigner([]byte("another key"), time.Hour).Issue("ABC123", "p1"), "ABC123", "p1", ErrInvalid},
+		{"random key", NewSigner(nil, time.Hour).Issue("ABC123", "p1"), "ABC123", "p1", ErrInvalid},
+		{"bad mac", payload + "." + enc.EncodeToString([]byte("not the mac")), "ABC123", "p1", ErrInvalid},
+		{"mac not base64", payload + ".!!!", "ABC123", "p1", ErrInvalid},
+		{"payload swapped", enc.EncodeToString([]byte("ABC123\x00p2\x009999999999")) + "." + mac, "ABC123", "p2", ErrInvalid},
+		{"no separator", payload + mac, "ABC123", "p1", ErrInvalid},
+		{"empty", "", "ABC123", "p1", ErrInvalid},
+		{"missing field", forge("ABC123\x00p1"), "ABC123", "p1", ErrInvalid},
+		{"bad expiry", forge("ABC123\x00p1\x00soon"), "ABC123", "p1", ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Verify(tt.token, tt.room, tt.player); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+/* ===== Graceful shutdown ===== */
+
+var (
+	// checkpointPath is where room state is flushed on shutdown and read
+	// back on startup.
+	checkpointPath = "monopoly-state.json"
+
+	// restartETA is how long clients are told to wait before reconnecting.
+	restartETA = 30 * time.Second
+
+	// shutdownTimeout bounds the whole shutdown sequence.
+	shutdownTimeout = 10 * time.Second
+
+	// conns holds every open WebSocket and SSE stream, seated or not;
+	// wsConns counts the handlers still running.
+	conns   = make(map[*Client]struct{})
+	wsConns sync.WaitGroup
+
+	// draining is set once closeConns starts: the rooms torn down after it
+	// are checkpointed, not finished. Guarded by mu.
+	draining bool
+)
+
+// shutdown stops accepting connections, warns every room, checkpoints room
+// state and closes the sockets with 1012 (service restart). It returns once
+// every WebSocket handler has finished or shutdownTimeout has passed.
+func shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// srv.Shutdown waits for in-flight requests, and SSE streams only end
+	// when their client is closed, so close everything as soon as the
+	// listeners stop rather than after Shutdown returns.
+	closed := make(chan struct{})
+	srv.RegisterOnShutdown(func() {
+		defer close(closed)
+		closeConns()
+	})
+	slog.Info("shutting down: no longer accepting connections")
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("http shutdown", "err", err)
+	}
+	select {
+	case <-closed:
+	case <-ctx.Done():
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wsConns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("shutdown complete")
+	case <-ctx.Done():
+		slog.Warn("shutdown timed out; exiting anyway", "timeout", shutdownTimeout)
+	}
+}
+
+// closeConns warns every room, saves the checkpoint and then closes every
+// WebSocket and SSE stream.
+func closeConns() {
+	names := openRooms()
+	mu.Lock()
+	draining = true
+	open := make([]*Client, 0, len(conns))
+	for c := range conns {
+		open = append(open, c)
+	}
+	mu.Unlock()
+
+	returnAt := time.Now().Add(restartETA).UnixMilli()
+	for _, room := range names {
+		broadcast(room, &protocol.ServerShutdown{Reason: "Server restarting", ReturnAt: returnAt})
+	}
+
+	// Checkpoint before the sockets close: onClose tears rooms down.
+	if err := saveCheckpoint(checkpointPath); err != nil {
+		slog.Error("checkpoint", "path", checkpointPath, "err", err)
+	}
+
+	for _, c := range open {
+		c.close(websocket.CloseServiceRestart, "server restarting")
+	}
+}
+
+// isDraining reports whether shutdown has started closing connections.
+func isDraining() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return draining
+}
+
+func trackConn(c *Client) {
+	wsConns.Add(1)
+	mu.Lock()
+	conns[c] = struct{}{}
+	mu.Unlock()
+}
+
+func untrackConn(c *Client) {
+	mu.Lock()
+	delete(conns, c)
+	mu.Unlock()
+	wsConns.Done()
+}
+
+/* ===== Checkpoints ===== */
+
+type checkpoint struct {
+	SavedAt time.Time       `json:"savedAt"`
+	Rooms   []game.Snapshot `json:"rooms"`
+	Lobby   []roomMeta      `json:"lobby"`
+	Hooks   []webhook       `json:"webhooks,omitempty"`
+}
+
+// saveCheckpoint writes every open room to path, atomically.
+func saveCheckpoint(path string) error {
+	mu.Lock()
+	list := make([]*game.Room, 0, len(games))
+	for id, g := range games {
+		if replays[id] == nil {
+			list = append(list, g)
+		}
+	}
+	metas := make([]roomMeta, 0, len(lobby))
+	for _, m := range lobby {
+		if !m.Replay { // the replay itself is not saved
+			metas = append(metas, *m)
+		}
+	}
+	hookList := make([]webhook, 0, len(hooks))
+	for _, h := range hooks {
+		hookList = append(hookList, webhook{ID: h.ID, URL: h.URL, Room: h.Room, Events: h.Events, Secret: h.Secret, Created: h.Created})
+	}
+	mu.Unlock()
+
+	cp := checkpoint{SavedAt: time.Now(), Rooms: make([]game.Snapshot, 0, len(list)), Lobby: metas, Hooks: hookList}
+	for _, g := range list {
+		cp.Rooms = append(cp.Rooms, g.Snapshot())
+	}
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	slog.Info("checkpointed rooms", "rooms", len(cp.Rooms), "path", path)
+	return nil
+}
+
+// loadCheckpoint restores the rooms saved by the last shutdown. Players can
+// only reclaim their seats with the tokens they already hold, so this is
+// skipped when the session secret is not stable across restarts.
+func loadCheckpoint(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return err
+	}
+
+	// Webhooks do not depend on seats, so they come back regardless.
+	mu.Lock()
+	for _, h := range cp.Hooks {
+		hooks[h.ID] = &h
+	}
+	mu.Unlock()
+	if len(cp.Hooks) > 0 {
+		slog.Info("restored webhooks", "webhooks", len(cp.Hooks), "path", path)
+	}
+
+	if cfg.SessionSecret == "" {
+		slog.Warn("not restoring checkpoint: configure a session secret so players can reclaim their seats", "path", path)
+		return nil
+	}
+	// Idle expiry counts from now, not from before the restart.
+	now := time.Now()
+	mu.Lock()
+	for _, m := range cp.Lobby {
+		m.LastActive = now
+		lobby[m.ID] = &m
+	}
+	for _, s := range cp.Rooms {
+		games[s.ID] = game.Restore(s, newDice())
+		if lobby[s.ID] == nil { // saved before rooms had lobby entries
+			lobby[s.ID] = &roomMeta{ID: s.ID, Name: "Room " + s.ID, Created: now, LastActive: now}
+		}
+	}
+	n := len(lobby)
+	mu.Unlock()
+	slog.Info("restored rooms", "rooms", n, "games", len(cp.Rooms), "path", path, "saved", cp.SavedAt)
+	return nil
+}
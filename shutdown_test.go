@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"monopoly/game"
+)
+
+func TestShutdownCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	oldPath, oldSecret := checkpointPath, cfg.SessionSecret
+	checkpointPath, cfg.SessionSecret = path, "test secret"
+	t.Cleanup(func() {
+		checkpointPath, cfg.SessionSecret = oldPath, oldSecret
+		mu.Lock()
+		draining = false
+		mu.Unlock()
+	})
+
+	room := newLobbyRoom(t, "")
+	h, _ := startHookTest(t) // removed before the room is closed
+	cs := seatTestClients(t, room, game.Player{ID: "a", Name: "Alice"}, game.Player{ID: "b", Name: "Bob"})
+	completed := metricLine(t, "monopoly_games_completed_total")
+
+	closeConns()
+	for _, c := range cs { // as each handler would once its socket closes
+		onClose(c)
+	}
+	if getGame(room) != nil {
+		t.Fatal("room still open after every player left")
+	}
+	if got := metricLine(t, "monopoly_games_completed_total"); got != completed {
+		t.Errorf("shutdown counted a completed game: %q, was %q", got, completed)
+	}
+	mu.Lock()
+	st := h.status
+	mu.Unlock()
+	if len(st.Recent) != 0 {
+		t.Errorf("shutdown fired webhooks: %+v", st.Recent)
+	}
+
+	if err := loadCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	g := getGame(room)
+	if g == nil {
+		t.Fatal("room not restored from the checkpoint")
+	}
+	// Nobody is seated until they resume, but both keep their place.
+	if pos := g.Snapshot().Positions; len(pos) != 2 {
+		t.Errorf("restored positions %v, want a and b", pos)
+	}
+	mu.Lock()
+	restored := lobby[room] != nil
+	mu.Unlock()
+	if !restored {
+		t.Error("lobby entry not restored")
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"monopoly/errcode"
+	"monopoly/game"
+	"monopoly/protocol"
+)
+
+/* ===== SSE: /rooms/{room}/stream ===== */
+
+// sseKeepalive is how often an idle stream gets a comment line, so proxies
+// keep it open.
+const sseKeepalive = 15 * time.Second
+
+// roomStreamHTTP is a Server-Sent Events alternative to /ws. Every message
+// the room broadcasts arrives as a "message" event whose data is the same
+// JSON a WebSocket client gets, with the event log sequence number as its
+// id; reconnecting with Last-Event-ID replays what was missed. Messages for
+// this connection only (session, yourTurn, replies) have no id.
+//
+// With ?playerId=&name= (plus token= when reclaiming a seat, password= for
+// protected rooms) the stream seats the player exactly like a WebSocket
+// resume, and the seat is held while the stream is open. Commands are then
+// POSTed to /rooms/{room}/commands or /roll with the session token. Without
+// playerId the stream is read-only.
+func roomStreamHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, errorMsg("", errMethodNotAllowed))
+		return
+	}
+	if ok, retry := allow(ipLimit, "ip", remoteIP(r)); !ok {
+		writeError(w, rateLimitedMsg("", retry))
+		return
+	}
+	room := r.PathValue("room")
+	q := r.URL.Query()
+
+	client := newClient(nil, remoteIP(r))
+	client.stream = true
+	trackConn(client)
+	defer untrackConn(client)
+
+	if pid := q.Get("playerId"); pid != "" {
+		in := &protocol.Resume{PlayerID: pid, Name: q.Get("name"), Room: room, Token: q.Get("token"), Password: q.Get("password")}
+		if err := join(client, in); err != nil {
+			writeError(w, errorMsg("", err))
+			return
+		}
+		defer onClose(client)
+	} else if _, ok := readableRoom(w, r); !ok {
+		return
+	}
+	defer client.close(websocket.CloseNormalClosure, "")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s := &sseStream{w: w, rc: http.NewResponseController(w), room: room}
+	defer s.unsubscribe()
+
+	lastID, resuming := lastEventID(r)
+	s.follow(getEventLog(room), lastID, resuming)
+	if err := s.flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case b := <-client.out:
+			err = s.write(0, b)
+		case <-s.notify:
+			err = s.catchUp()
+		case <-keepalive.C:
+			// The room's game (and its log) is replaced when the room empties
+			// and fills again; spectators follow the new one.
+			if l := getEventLog(room); l != s.log {
+				s.unsubscribe()
+				err = s.follow(l, 0, false)
+			} else {
+				_, err = io.WriteString(w, ": keepalive\n\n")
+			}
+		case <-client.done:
+			for {
+				select {
+				case b := <-client.out:
+					_ = s.write(0, b)
+				default:
+					_ = s.flush()
+					return
+				}
+			}
+		case <-r.Context().Done():
+			return
+		}
+		if err == nil {
+			err = s.flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// lastEventID reads the Last-Event-ID header browsers send on reconnect.
+func lastEventID(r *http.Request) (uint64, bool) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("lastEventId")
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	return id, err == nil
+}
+
+type sseStream struct {
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	room string
+
+	log    *eventLog
+	notify chan struct{}
+	last   uint64 // newest event sent
+}
+
+// follow starts streaming l after event lastID. A fresh connection, or one
+// that has fallen out of the log, first gets a state snapshot instead of a
+// replay.
+func (s *sseStream) follow(l *eventLog, lastID uint64, resuming bool) error {
+	s.log = l
+	if l == nil {
+		s.notify = nil
+		return s.snapshot()
+	}
+	s.notify = l.subscribe()
+	if resuming && l.covers(lastID) {
+		s.last = lastID
+		return s.catchUp()
+	}
+	s.last = l.last()
+	return s.snapshot()
+}
+
+func (s *sseStream) unsubscribe() {
+	if s.log != nil {
+		s.log.unsubscribe(s.notify)
+	}
+}
+
+func (s *sseStream) snapshot() error {
+	if msgs := replayCatchUp(s.room); msgs != nil {
+		for _, b := range msgs {
+			if err := s.write(0, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	g := getGame(s.room)
+	if g == nil {
+		g = game.NewRoom(s.room, nil)
+	}
+	b, err := protocol.Marshal(stateMsg(g.View()))
+	if err != nil {
+		return err
+	}
+	return s.write(0, b)
+}
+
+func (s *sseStream) catchUp() error {
+	for _, e := range s.log.since(s.last) {
+		if err := s.write(e.Seq, e.Message); err != nil {
+			return err
+		}
+		messagesOut.Inc(e.Type)
+		s.last = e.Seq
+	}
+	return nil
+}
+
+// write sends one event. Message JSON never contains a raw newline, so it
+// always fits on a single data line.
+func (s *sseStream) write(id uint64, data []byte) error {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(writeWait))
+	var err error
+	if id > 0 {
+		_, err = fmt.Fprintf(s.w, "id: %d\ndata: %s\n\n", id, data)
+	} else {
+		_, err = fmt.Fprintf(s.w, "data: %s\n\n", data)
+	}
+	return err
+}
+
+func (s *sseStream) flush() error {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(writeWait))
+	return s.rc.Flush()
+}
+
+/* ===== REST: /rooms/{room}/commands ===== */
+
+// maxCommandBody bounds a POSTed command.
+const maxCommandBody = 64 << 10
+
+// roomCommandHTTP runs one inbound protocol message (roll, reveal, sync,
+// leave, ...) for the player its bearer session token names, through the
+// same dispatch as WebSocket messages. The player must have a connection
+// open (usually the SSE stream), which receives any direct replies; the
+// response is the ack or error.
+func roomCommandHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errorMsg("", errMethodNotAllowed))
+		return
+	}
+	room := r.PathValue("room")
+	claims, err := sessions.Parse(bearerToken(r))
+	if err != nil || claims.Room != room {
+		writeError(w, errorMsg("", errcode.New(errcode.Unauthorized, "session token for this room required")))
+		return
+	}
+	client := getClientByID(room, claims.PlayerID)
+	if client == nil {
+		writeError(w, errorMsg("", errcode.New(errcode.NotSeated, "player not connected in room; open the stream first")))
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCommandBody))
+	if err != nil {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "body too large")))
+		return
+	}
+	msg, decErr := protocol.Decode(data)
+	if ok, retry := allowInbound(client, msg); !ok {
+		writeError(w, rateLimitedMsg(protocol.PeekID(data), retry))
+		return
+	}
+	if decErr == nil {
+		if _, ok := msg.(*protocol.Resume); ok {
+			decErr = errors.New("join by opening the stream")
+		}
+	}
+	if decErr != nil {
+		messagesIn.Inc("invalid")
+		writeError(w, errorMsg(protocol.PeekID(data), errcode.New(errcode.BadMessage, decErr.Error())))
+		return
+	}
+	messagesIn.Inc(protocol.Type(msg))
+
+	// The bearer token already proved the seat; commands need not repeat it.
+	if tok := protocol.SessionToken(msg); tok != nil && *tok == "" {
+		*tok = bearerToken(r)
+	}
+	closeConn, err := dispatch(client, msg)
+	logCommand(client, msg, err)
+	if err != nil {
+		writeError(w, errorMsg(protocol.RequestID(msg), err))
+		return
+	}
+	if closeConn {
+		client.close(websocket.CloseNormalClosure, "")
+	}
+	writeMessage(w, &protocol.Ack{Ref: protocol.RequestID(msg)})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"monopoly/protocol"
+)
+
+type sseEvent struct {
+	id   string
+	typ  string // the message's type
+	data string
+}
+
+// openStream GETs an SSE stream and parses its events in the background. The
+// channel is closed when the stream ends.
+func openStream(t *testing.T, url string, header map[string]string) <-chan sseEvent {
+	t.Helper()
+	req, _ := http.NewRequest("GET", url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(res.Body)
+		t.Fatalf("GET %s = %d: %s", url, res.StatusCode, b)
+	}
+
+	events := make(chan sseEvent, 64)
+	go func() {
+		defer close(events)
+		var e sseEvent
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				if e.data != "" {
+					var env protocol.Envelope
+					_ = json.Unmarshal([]byte(e.data), &env)
+					e.typ = env.Type
+					events <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				e.id = line[len("id: "):]
+			case strings.HasPrefix(line, "data: "):
+				e.data = line[len("data: "):]
+			}
+		}
+	}()
+	return events
+}
+
+// eventSeq is e's id as a sequence number, 0 if it has none.
+func eventSeq(e sseEvent) uint64 {
+	n, _ := strconv.ParseUint(e.id, 10, 64)
+	return n
+}
+
+// nextEvent returns the next event, failing after 5s or at the end of the
+// stream.
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after 5s")
+	}
+	return sseEvent{}
+}
+
+func TestSSEResume(t *testing.T) {
+	srv := startTestServer(t)
+	room := newLobbyRoom(t, "")
+	c := newTestConn(t)
+	tok := seat(t, c, room, "p1", "")
+	roll := func() {
+		t.Helper()
+		if _, err := dispatch(c, &protocol.Roll{Token: tok}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roll()
+	roll()
+	l := getEventLog(room)
+	last := l.last()
+	url := srv.URL + "/rooms/" + room + "/stream"
+
+	// A fresh stream starts from a snapshot, then follows the log.
+	fresh := openStream(t, url, nil)
+	if e := nextEvent(t, fresh); e.typ != "state" || e.id != "" {
+		t.Errorf("fresh stream opened with %+v, want a state snapshot without an id", e)
+	}
+	roll()
+	// Turn notices are sent asynchronously, so the log may have grown
+	// since last was read.
+	if e := nextEvent(t, fresh); eventSeq(e) <= last {
+		t.Errorf("live event id %q, want one after %d", e.id, last)
+	}
+
+	// Reconnecting replays exactly what was missed, in order.
+	from := last - 2
+	resumed := openStream(t, url, map[string]string{"Last-Event-ID": strconv.FormatUint(from, 10)})
+	for _, want := range l.since(from) {
+		e := nextEvent(t, resumed)
+		if e.id != strconv.FormatUint(want.Seq, 10) || e.data != string(want.Message) {
+			t.Fatalf("replayed %+v, want event %d (%s)", e, want.Seq, want.Type)
+		}
+	}
+
+	// An id the log does not cover gets a snapshot again.
+	stale := openStream(t, url, map[string]string{"Last-Event-ID": strconv.FormatUint(l.last()+100, 10)})
+	if e := nextEvent(t, stale); e.typ != "state" {
+		t.Errorf("unknown Last-Event-ID: first event %+v, want a snapshot", e)
+	}
+}
+
+func TestSSECommandAuth(t *testing.T) {
+	srv := startTestServer(t)
+	room, other := newLobbyRoom(t, ""), newLobbyRoom(t, "")
+	pid := "p-" + room // rolls are throttled per player id
+	tok := seat(t, newTestConn(t), room, pid, "")
+	otherTok := seat(t, newTestConn(t), other, "p2", "")
+
+	post := func(token, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+"/rooms/"+room+"/commands", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	for _, tt := range []struct {
+		name, token string
+		want        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"bad token", "not-a-token", http.StatusUnauthorized},
+		{"tampered token", tok + "x", http.StatusUnauthorized},
+		{"other room's token", otherTok, http.StatusUnauthorized},
+		{"seated player", tok, http.StatusOK},
+	} {
+		if got := post(tt.token, `{"type":"roll"}`); got != tt.want {
+			t.Errorf("%s: %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := post(tok, `{"type":"resume","playerId":"`+pid+`","room":"`+room+`"}`); got != http.StatusBadRequest {
+		t.Errorf("resume over POST: %d, want 400", got)
+	}
+}
+
+func TestSSEShutdown(t *testing.T) {
+	oldPath := checkpointPath
+	checkpointPath = filepath.Join(t.TempDir(), "state.json")
+	t.Cleanup(func() {
+		checkpointPath = oldPath
+		mu.Lock()
+		draining = false
+		mu.Unlock()
+	})
+
+	srv := startTestServer(t)
+	room := newLobbyRoom(t, "")
+	events := openStream(t, srv.URL+"/rooms/"+room+"/stream?playerId=p1&name=P1", nil)
+	for e := nextEvent(t, events); e.typ != "session"; e = nextEvent(t, events) {
+	}
+
+	closeConns()
+	var shutdown bool
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if !shutdown {
+					t.Error("stream closed without a serverShutdown message")
+				}
+				return
+			}
+			shutdown = shutdown || e.typ == "serverShutdown"
+		case <-deadline:
+			t.Fatal("stream still open 5s after shutdown")
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+/* ===== Static frontend ===== */
+
+//go:embed index.html game.html
+var staticFS embed.FS
+
+type staticFile struct {
+	data []byte
+	etag string
+}
+
+// embedded holds the frontend compiled into the binary, with content-hash
+// ETags computed once at startup.
+var embedded = func() map[string]staticFile {
+	files := map[string]staticFile{}
+	for _, name := range []string{"index.html", "game.html"} {
+		b, err := staticFS.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+		sum := sha256.Sum256(b)
+		files[name] = staticFile{data: b, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	}
+	return files
+}()
+
+// serveStatic serves a frontend file. Pages are not fingerprinted, so
+// browsers may cache them but must revalidate; the ETag makes that a 304.
+// With a static dir configured the file is read from disk on every request.
+func serveStatic(w http.ResponseWriter, r *http.Request, name string) {
+	w.Header().Set("Cache-Control", "no-cache")
+	if cfg.StaticDir != "" {
+		http.ServeFile(w, r, filepath.Join(cfg.StaticDir, name))
+		return
+	}
+	f, ok := embedded[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("ETag", f.etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(f.data))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticETag(t *testing.T) {
+	srv := startTestServer(t)
+	get := func(path, etag string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+
+	first := get("/", "")
+	body, _ := io.ReadAll(first.Body)
+	etag := first.Header.Get("ETag")
+	if first.StatusCode != http.StatusOK || string(body) != string(embedded["index.html"].data) {
+		t.Fatalf("GET / = %d with %d bytes, want the embedded index.html", first.StatusCode, len(body))
+	}
+	if etag == "" || first.Header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("headers = %v, want an ETag and no-cache", first.Header)
+	}
+
+	if res := get("/index.html", etag); res.StatusCode != http.StatusNotModified {
+		t.Errorf("revalidating with the ETag = %d, want 304", res.StatusCode)
+	}
+	if res := get("/", `"stale"`); res.StatusCode != http.StatusOK {
+		t.Errorf("stale ETag = %d, want 200", res.StatusCode)
+	}
+	if res := get("/game.html", etag); res.StatusCode != http.StatusOK || res.Header.Get("ETag") == etag {
+		t.Errorf("game.html = %d with ETag %s, want 200 and its own ETag", res.StatusCode, res.Header.Get("ETag"))
+	}
+	if res := get("/nope.html", ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown page = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := cfg.StaticDir
+	cfg.StaticDir = dir
+	t.Cleanup(func() { cfg.StaticDir = old })
+
+	res, err := http.Get(startTestServer(t).URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if body, _ := io.ReadAll(res.Body); string(body) != "edited" {
+		t.Errorf("GET / = %q, want the file from the static dir", body)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+/* ===== TLS ===== */
+
+// newServer builds the main server around h. With TLS configured it serves
+// HTTPS only and tells browsers to stick to it.
+func newServer(h http.Handler) *http.Server {
+	srv := &http.Server{Addr: cfg.Addr, Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	if cfg.TLS() {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		srv.Handler = withHSTS(h)
+	}
+	return srv
+}
+
+// withHSTS asks browsers to use HTTPS for this host from now on, so pages
+// never load over plain HTTP and their WebSocket is always wss://.
+func withHSTS(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newRedirectServer listens on cfg.RedirectAddr and sends every request to
+// the same path on the HTTPS listener. nil when no redirect is configured.
+func newRedirectServer() *http.Server {
+	if cfg.RedirectAddr == "" {
+		return nil
+	}
+	_, tlsPort, _ := net.SplitHostPort(cfg.Addr)
+	return &http.Server{
+		Addr:              cfg.RedirectAddr,
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler:           http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { redirectHTTPS(w, r, tlsPort) }),
+	}
+}
+
+func redirectHTTPS(w http.ResponseWriter, r *http.Request, port string) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	if port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		host, port, target, want string
+	}{
+		{"example.com", "443", "/game.html?room=ABC", "https://example.com/game.html?room=ABC"},
+		{"example.com:80", "", "/", "https://example.com/"},
+		{"example.com:8080", "8443", "/rooms", "https://example.com:8443/rooms"},
+		{"[::1]:80", "8443", "/", "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectHTTPS(w, r, tt.port)
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.want {
+			t.Errorf("%s%s to port %q = %d %s, want 308 %s", tt.host, tt.target, tt.port, w.Code, w.Header().Get("Location"), tt.want)
+		}
+	}
+}
+
+func TestNewServerTLS(t *testing.T) {
+	old := cfg
+	t.Cleanup(func() { cfg = old })
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	cfg.TLSCert, cfg.TLSKey = "", ""
+	w := httptest.NewRecorder()
+	newServer(ok).Handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if hsts := w.Header().Get("Strict-Transport-Security"); hsts != "" {
+		t.Errorf("plain HTTP sent HSTS %q", hsts)
+	}
+	if newRedirectServer() != nil {
+		t.Error("redirect server without a redirect addr")
+	}
+
+	cfg.TLSCert, cfg.TLSKey, cfg.Addr, cfg.RedirectAddr = "cert.pem", "key.pem", ":8443", ":8080"
+	srv := newServer(ok)
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Header().Get("Strict-Transport-Security") == "" || srv.TLSConfig == nil {
+		t.Error("TLS server without HSTS or a TLS config")
+	}
+	rs := newRedirectServer()
+	if rs == nil || rs.Addr != ":8080" {
+		t.Fatalf("redirect server = %+v, want one on :8080", rs)
+	}
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/x", nil)
+	r.Host = "example.com:8080"
+	rs.Handler.ServeHTTP(w, r)
+	if loc := w.Header().Get("Location"); loc != "https://example.com:8443/x" {
+		t.Errorf("redirect to %q, want the TLS port", loc)
+	}
+}
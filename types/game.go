@@ -2,7 +2,9 @@ package types
 
 import (
 	"fmt"
-	"math/rand"
+	"log/slog"
+
+	"monopoly/dice"
 )
 
 type MonopolyGame interface {
@@ -18,34 +20,37 @@ type MonopolyGame interface {
 }
 
 func (p *Players) RollDice() {
-	fmt.Println(p.Name, "is rolling the dice...")
-	// Simulate rolling two dice
-	val := 1 + rand.Intn(6) + 1 + rand.Intn(6)
-	fmt.Println(p.Name, "rolled a : ", val)
+	slog.Debug("rolling dice", "player", p.Name)
+	if p.Dice == nil {
+		p.Dice = dice.NewRandom()
+	}
+	d1, d2 := p.Dice.Roll()
+	val := d1 + d2
+	slog.Info("rolled", "player", p.Name, "total", val)
 	p.Move(val)
 }
 
 func (p *Players) Move(position int) {
-	fmt.Println(p.Name, "Moved a : ", position)
+	slog.Debug("moving", "player", p.Name, "by", position)
 	p.Position += position - 1
 	if p.Position >= len(AllProperties) {
 		p.Position = p.Position % len(AllProperties)
 	}
 
 	property := TestDataProperty[p.Position]
-	fmt.Println(p.Name, "landed on", property)
+	slog.Info("landed", "player", p.Name, "property", property.PropertyName)
 
 	if p.CheckProperty(property) {
-		fmt.Println(p.Name, "already owns", property)
+		slog.Info("already owned", "player", p.Name, "property", property.PropertyName)
 		p.PayRent(property.Rent)
 		return
 	}
 
-	p.AskUserToBuyProperty(property)
+	p.AskUserToBuyProperty(TestDataProperty)
 
 	for _, property := range TestDataProperty {
 		if p.CheckProperty(property) {
-			fmt.Println(p.Name, "already owns", property)
+			slog.Info("already owned", "player", p.Name, "property", property.PropertyName)
 			p.PayRent(property.Rent)
 			return
 		}
@@ -109,10 +114,10 @@ func (p *Players) AskUserToBuyProperty(TestDataProperty map[int]Property) {
 				RemoveProperty(p.Position, property.PropertyName)
 			}
 			if NoChoice[response] {
-				fmt.Println(p.Name, "decided not to buy", property.PropertyName)
+				slog.Info("declined to buy", "player", p.Name, "property", property.PropertyName)
 			}
 		} else {
-			fmt.Println("Not enough balance to buy the property:", property.PropertyName)
+			slog.Info("cannot afford property", "player", p.Name, "property", property.PropertyName, "balance", p.Balance)
 		}
 
 	}
@@ -126,30 +131,27 @@ func (p *Players) AddProperty(propertyName string, price int, rent int) {
 		Rent:         rent,  // Rent will be set later when buying
 		Owner:        p.Name,
 	}
-	fmt.Printf("Adding property  %s to %s \n", propertyName, p.Name)
+	slog.Info("property added", "player", p.Name, "property", propertyName)
 	p.Properties = append(p.Properties, propertyDetials)
 }
 
 func (p *Players) RemoveBalance(amount int) {
-	fmt.Printf("Removing balance  %d from %s \n", amount, p.Name)
 	p.Balance -= amount
-	fmt.Printf("Current balance for user %s from %d \n", p.Name, p.Balance)
-
+	slog.Info("balance debited", "player", p.Name, "amount", amount, "balance", p.Balance)
 }
 
 func RemoveProperty(indexToRemove int, propertyName string) {
-	fmt.Println("Removing property:", propertyName)
+	slog.Debug("property removed from bank", "property", propertyName)
 	AllProperties = append(AllProperties[:indexToRemove], AllProperties[indexToRemove+1:]...)
 }
 
 func (p *Players) PayRent(rent int) {
-	fmt.Println(p.Name, "paying rent to :", rent)
 	p.Balance -= rent
+	slog.Info("rent paid", "player", p.Name, "rent", rent, "balance", p.Balance)
 	if p.Balance < 0 {
-		fmt.Println(p.Name, "has run out of balance. Game Over!")
+		slog.Info("out of balance; game over", "player", p.Name)
 		GameEnd = true
 	}
-	fmt.Println("Current balance after paying rent:", p.Balance)
 }
 
 func (p *Players) PrintProperties() {
@@ -1,11 +1,16 @@
 package types
 
+import "monopoly/dice"
+
 // Define a struct
 type Players struct {
 	Name       string
 	Balance    int
 	Position   int
 	Properties []Property
+
+	// Dice drives RollDice. A random seed is used when nil.
+	Dice dice.Dice
 }
 
 type Property struct {
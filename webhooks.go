@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	mrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"monopoly/errcode"
+)
+
+/* ===== Webhooks ===== */
+
+// Webhook event types. Hooks subscribe to a subset, or to all of them.
+// Players only move around the board: there is no money, so no purchases,
+// bankruptcies or winner, and no events for them.
+const (
+	hookRoomCreated  = "room.created"
+	hookRoomClosed   = "room.closed"
+	hookGameStarted  = "game.started" // first player seated in an empty room
+	hookGameEnded    = "game.ended"   // last player left
+	hookGamePaused   = "game.paused"
+	hookGameResumed  = "game.resumed"
+	hookPlayerJoined = "player.joined"
+	hookPlayerLeft   = "player.left"
+	hookDiceRolled   = "dice.rolled"
+	hookPing         = "ping" // sent by POST /admin/webhooks/{id}/test
+)
+
+var hookEvents = []string{
+	hookRoomCreated, hookRoomClosed, hookGameStarted, hookGameEnded, hookGamePaused,
+	hookGameResumed, hookPlayerJoined, hookPlayerLeft, hookDiceRolled, hookPing,
+}
+
+const (
+	hookWorkers     = 4
+	hookTimeout     = 10 * time.Second
+	hookMaxAttempts = 6
+	hookRecent      = 20 // deliveries kept per hook for the admin API
+)
+
+// webhook is one registered endpoint. Payloads are signed with Secret.
+type webhook struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Room    string    `json:"room,omitempty"`   // "" for every room
+	Events  []string  `json:"events,omitempty"` // empty for every event
+	Secret  string    `json:"secret"`
+	Created time.Time `json:"created"`
+
+	status hookStatus
+}
+
+// hookStatus is the delivery history of a hook, guarded by mu.
+type hookStatus struct {
+	Delivered   int              `json:"delivered"`
+	Failed      int              `json:"failed"` // gave up after retries
+	Pending     int              `json:"pending"`
+	LastAttempt time.Time        `json:"lastAttempt,omitzero"`
+	LastSuccess time.Time        `json:"lastSuccess,omitzero"`
+	LastStatus  int              `json:"lastStatus,omitempty"` // HTTP status of the last attempt
+	LastError   string           `json:"lastError,omitempty"`
+	Recent      []deliveryRecord `json:"recent,omitempty"`
+}
+
+type deliveryRecord struct {
+	ID       string    `json:"id"`
+	Event    string    `json:"event"`
+	Room     string    `json:"room,omitempty"`
+	Attempts int       `json:"attempts"`
+	Status   string    `json:"status"` // pending, delivered or failed
+	Code     int       `json:"code,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// hookPayload is the JSON body POSTed to a hook.
+type hookPayload struct {
+	ID    string    `json:"id"`
+	Event string    `json:"event"`
+	Room  string    `json:"room,omitempty"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data,omitempty"`
+}
+
+type delivery struct {
+	hookID  string
+	id      string
+	event   string
+	room    string
+	body    []byte
+	attempt int
+}
+
+var (
+	// hooks holds the registered webhooks, guarded by mu.
+	hooks = make(map[string]*webhook)
+
+	hookQueue  = make(chan *delivery, 1024)
+	hookClient = &http.Client{Timeout: hookTimeout}
+
+	// Retries wait hookBackoffBase doubled per attempt, up to hookMaxBackoff.
+	hookBackoffBase = time.Second
+	hookMaxBackoff  = 5 * time.Minute
+)
+
+func (h *webhook) wants(event, room string) bool {
+	if h.Room != "" && h.Room != room {
+		return false
+	}
+	return len(h.Events) == 0 || slices.Contains(h.Events, event) || event == hookPing
+}
+
+func newHookID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// emitHook queues event for every hook that wants it. It never blocks;
+// callers must not hold mu.
+func emitHook(event, room string, data any) {
+	mu.Lock()
+	var targets []*webhook
+	for _, h := range hooks {
+		if h.wants(event, room) {
+			targets = append(targets, h)
+		}
+	}
+	mu.Unlock()
+	for _, h := range targets {
+		sendHook(h, event, room, data)
+	}
+}
+
+func sendHook(h *webhook, event, room string, data any) {
+	p := hookPayload{ID: newHookID(), Event: event, Room: room, Time: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(p)
+	if err != nil {
+		slog.Error("webhook payload", "event", event, "err", err)
+		return
+	}
+	d := &delivery{hookID: h.ID, id: p.ID, event: event, room: room, body: body}
+	mu.Lock()
+	h.status.Pending++
+	h.status.record(deliveryRecord{ID: d.id, Event: event, Room: room, Status: "pending", Time: p.Time})
+	mu.Unlock()
+	enqueueHook(d)
+}
+
+func enqueueHook(d *delivery) {
+	select {
+	case hookQueue <- d:
+	default:
+		finishHook(d, 0, "delivery queue full", false)
+	}
+}
+
+// runWebhooks delivers queued payloads until ctx is done. Failed deliveries
+// are retried with exponential backoff; retries still waiting at shutdown
+// are dropped.
+func runWebhooks(ctx context.Context) {
+	for range hookWorkers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-hookQueue:
+					deliverHook(ctx, d)
+				}
+			}
+		}()
+	}
+}
+
+func deliverHook(ctx context.Context, d *delivery) {
+	mu.Lock()
+	h := hooks[d.hookID]
+	var target, secret string
+	if h != nil {
+		target, secret = h.URL, h.Secret
+	}
+	mu.Unlock()
+	if h == nil {
+		return // unregistered since
+	}
+
+	d.attempt++
+	code, err := postHook(ctx, target, secret, d)
+	switch {
+	case err == nil:
+		finishHook(d, code, "", true)
+	case d.attempt < hookMaxAttempts && retryable(code):
+		noteHookAttempt(d, code, err.Error())
+		time.AfterFunc(hookBackoff(d.attempt), func() {
+			if ctx.Err() == nil {
+				enqueueHook(d)
+			}
+		})
+	default:
+		finishHook(d, code, err.Error(), false)
+	}
+}
+
+// postHook sends one attempt. The signature header is
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" under the secret>".
+func postHook(ctx context.Context, target, secret string, d *delivery) (int, error) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(d.body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "monopoly-webhooks/1")
+	req.Header.Set("X-Monopoly-Event", d.event)
+	req.Header.Set("X-Monopoly-Delivery", d.id)
+	req.Header.Set("X-Monopoly-Signature", "t="+ts+",v1="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether an attempt that got code (0 for a network
+// error) is worth repeating. Other client errors will not go away.
+func retryable(code int) bool {
+	return code == 0 || code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+// hookBackoff is 2s, 4s, 8s, ... capped at hookMaxBackoff, with up to 25%
+// jitter.
+func hookBackoff(attempt int) time.Duration {
+	d := min(hookBackoffBase<<attempt, hookMaxBackoff)
+	return d + mrand.N(d/4+1)
+}
+
+func noteHookAttempt(d *delivery, code int, errText string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if h := hooks[d.hookID]; h != nil {
+		h.status.attempted(d, code, errText, "pending")
+	}
+}
+
+func finishHook(d *delivery, code int, errText string, ok bool) {
+	result := "failed"
+	if ok {
+		result = "delivered"
+	}
+	hookDeliveries.Inc(result)
+
+	mu.Lock()
+	h := hooks[d.hookID]
+	if h != nil {
+		h.status.Pending--
+		if ok {
+			h.status.Delivered++
+			h.status.LastSuccess = time.Now()
+		} else {
+			h.status.Failed++
+		}
+		h.status.attempted(d, code, errText, result)
+	}
+	mu.Unlock()
+	if !ok && h != nil {
+		slog.Warn("webhook delivery failed", "hook", d.hookID, "event", d.event, logRoom, d.room, "attempts", d.attempt, "err", errText)
+	}
+}
+
+func (s *hookStatus) attempted(d *delivery, code int, errText, result string) {
+	s.LastAttempt = time.Now()
+	s.LastStatus = code
+	s.LastError = errText
+	for i := range s.Recent {
+		if r := &s.Recent[i]; r.ID == d.id {
+			r.Attempts, r.Status, r.Code, r.Error = d.attempt, result, code, errText
+		}
+	}
+}
+
+func (s *hookStatus) record(r deliveryRecord) {
+	s.Recent = append(s.Recent, r)
+	if len(s.Recent) > hookRecent {
+		s.Recent = slices.Delete(s.Recent, 0, len(s.Recent)-hookRecent)
+	}
+}
+
+/* ===== Admin: /admin/webhooks ===== */
+
+// hookView is a webhook as the admin API shows it: the secret is only
+// returned when the hook is created.
+type hookView struct {
+	ID      string     `json:"id"`
+	URL     string     `json:"url"`
+	Room    string     `json:"room,omitempty"`
+	Events  []string   `json:"events,omitempty"`
+	Secret  string     `json:"secret,omitempty"`
+	Created time.Time  `json:"created"`
+	Status  hookStatus `json:"status"`
+}
+
+// viewLocked copies h for the admin API. Callers hold mu.
+func (h *webhook) viewLocked(recent bool) hookView {
+	v := hookView{ID: h.ID, URL: h.URL, Room: h.Room, Events: h.Events, Created: h.Created, Status: h.status}
+	v.Status.Recent = nil
+	if recent {
+		v.Status.Recent = slices.Clone(h.status.Recent)
+		slices.Reverse(v.Status.Recent) // newest first
+	}
+	return v
+}
+
+func adminHooksHTTP(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	out := make([]hookView, 0, len(hooks))
+	for _, h := range hooks {
+		out = append(out, h.viewLocked(false))
+	}
+	mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	writeJSON(w, out)
+}
+
+// adminAddHookHTTP registers a hook from {"url", "room", "events",
+// "secret"}. A secret is generated when none is given.
+func adminAddHookHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string   `json:"url"`
+		Room   string   `json:"room"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "bad json")))
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeError(w, errorMsg("", errcode.New(errcode.BadMessage, "url must be an absolute http(s) URL")))
+		return
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(hookEvents, e) {
+			writeError(w, errorMsg("", errcode.New(errcode.BadMessage, fmt.Sprintf("unknown event %q", e))))
+			return
+		}
+	}
+	if req.Secret == "" {
+		req.Secret = newHookID() + newHookID()
+	}
+	h := &webhook{ID: newHookID(), URL: req.URL, Room: req.Room, Events: req.Events, Secret: req.Secret, Created: time.Now()}
+
+	mu.Lock()
+	hooks[h.ID] = h
+	v := h.viewLocked(false)
+	mu.Unlock()
+	v.Secret = h.Secret
+	slog.Info("admin command", logCmd, "webhook.add", "hook", h.ID, "url", h.URL, logRoom, h.Room, "events", h.Events)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, v)
+}
+
+// adminHookHTTP returns one hook with its most recent deliveries.
+func adminHookHTTP(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	h := hooks[r.PathValue("id")]
+	var v hookView
+	if h != nil {
+		v = h.viewLocked(true)
+	}
+	mu.Unlock()
+	if h == nil {
+		writeError(w, errorMsg("", errNoHook))
+		return
+	}
+	writeJSON(w, v)
+}
+
+func adminDeleteHookHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	mu.Lock()
+	_, ok := hooks[id]
+	delete(hooks, id)
+	mu.Unlock()
+	if !ok {
+		writeError(w, errorMsg("", errNoHook))
+		return
+	}
+	slog.Info("admin command", logCmd, "webhook.delete", "hook", id)
+	writeJSON(w, map[string]any{"ok": true})
+}
+
+// adminTestHookHTTP queues a ping to one hook.
+func adminTestHookHTTP(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	h := hooks[r.PathValue("id")]
+	mu.Unlock()
+	if h == nil {
+		writeError(w, errorMsg("", errNoHook))
+		return
+	}
+	sendHook(h, hookPing, h.Room, map[string]any{"hook": h.ID})
+	writeJSON(w, map[string]any{"ok": true})
+}
+
+var errNoHook = errcode.New(errcode.NotFound, "No webhook with that id.")
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookSink is a local stand-in for a webhook endpoint. It answers with the
+// queued status codes in turn, then 200.
+type hookSink struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []sinkRequest
+}
+
+type sinkRequest struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+func (s *hookSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, sinkRequest{header: r.Header.Clone(), body: body, at: time.Now()})
+	code := http.StatusOK
+	if len(s.statuses) > 0 {
+		code, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+	w.WriteHeader(code)
+}
+
+func (s *hookSink) received() []sinkRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sinkRequest(nil), s.requests...)
+}
+
+// startHookTest registers a hook pointing at a sink that answers statuses,
+// with the delivery workers running and fast retries.
+func startHookTest(t *testing.T, statuses ...int) (*webhook, *hookSink) {
+	t.Helper()
+	sink := &hookSink{statuses: statuses}
+	srv := httptest.NewServer(sink)
+	ctx, cancel := context.WithCancel(context.Background())
+	runWebhooks(ctx)
+
+	base := hookBackoffBase
+	hookBackoffBase = time.Millisecond
+	h := &webhook{ID: newHookID(), URL: srv.URL, Secret: "s3cret", Created: time.Now()}
+	mu.Lock()
+	hooks[h.ID] = h
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+		hookBackoffBase = base
+		mu.Lock()
+		delete(hooks, h.ID)
+		mu.Unlock()
+	})
+	return h, sink
+}
+
+// waitHook waits until h has no deliveries pending and returns its status.
+func waitHook(t *testing.T, h *webhook) hookStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		st := h.status
+		mu.Unlock()
+		if st.Pending == 0 && st.Delivered+st.Failed > 0 {
+			return st
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("delivery still pending after 5s")
+	return hookStatus{}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	h, sink := startHookTest(t)
+	emitHook(hookDiceRolled, "ROOM01", map[string]any{"player": "p1", "dice": []int{3, 4}})
+	if st := waitHook(t, h); st.Delivered != 1 {
+		t.Fatalf("status = %+v, want one delivery", st)
+	}
+
+	req := sink.received()[0]
+	if got := req.header.Get("X-Monopoly-Event"); got != hookDiceRolled {
+		t.Errorf("X-Monopoly-Event = %q", got)
+	}
+	var p hookPayload
+	if err := json.Unmarshal(req.body, &p); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if p.Event != hookDiceRolled || p.Room != "ROOM01" || p.ID != req.header.Get("X-Monopoly-Delivery") {
+		t.Errorf("payload = %+v", p)
+	}
+
+	// Recompute the signature as a receiver would.
+	sig := req.header.Get("X-Monopoly-Signature")
+	ts, v1, ok := strings.Cut(sig, ",v1=")
+	ts, tsOK := strings.CutPrefix(ts, "t=")
+	if !ok || !tsOK {
+		t.Fatalf("signature header %q", sig)
+	}
+	if n, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(n, 0)) > time.Minute {
+		t.Errorf("timestamp %q is not recent", ts)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(ts + "."))
+	mac.Write(req.body)
+	if want := hex.EncodeToString(mac.Sum(nil)); v1 != want {
+		t.Errorf("v1 = %s, want %s", v1, want)
+	}
+
+	// A tampered body no longer matches.
+	mac.Reset()
+	mac.Write([]byte(ts + "."))
+	mac.Write([]byte(strings.Replace(string(req.body), "ROOM01", "ROOM02", 1)))
+	if hex.EncodeToString(mac.Sum(nil)) == v1 {
+		t.Error("signature does not cover the body")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		attempts  int
+		delivered bool
+	}{
+		{"first try", nil, 1, true},
+		{"server errors then success", []int{503, 500}, 3, true},
+		{"rate limited then success", []int{429}, 2, true},
+		{"client error is final", []int{400}, 1, false},
+		{"gives up", []int{500, 500, 500, 500, 500, 500, 500}, hookMaxAttempts, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, sink := startHookTest(t, tt.statuses...)
+			emitHook(hookPlayerJoined, "ROOM01", nil)
+			st := waitHook(t, h)
+
+			reqs := sink.received()
+			if len(reqs) != tt.attempts {
+				t.Errorf("endpoint got %d attempts, want %d", len(reqs), tt.attempts)
+			}
+			if (st.Delivered == 1) != tt.delivered || st.Delivered+st.Failed != 1 {
+				t.Errorf("status = %+v, want delivered=%v", st, tt.delivered)
+			}
+			if len(st.Recent) != 1 || st.Recent[0].Attempts != tt.attempts {
+				t.Errorf("recent = %+v, want one record of %d attempts", st.Recent, tt.attempts)
+			}
+			for i := 1; i < len(reqs); i++ {
+				if reqs[i].header.Get("X-Monopoly-Delivery") != reqs[0].header.Get("X-Monopoly-Delivery") {
+					t.Error("a retry changed the delivery id")
+				}
+				if gap := reqs[i].at.Sub(reqs[i-1].at); gap < hookBackoffBase<<i {
+					t.Errorf("retry %d came after %v, want at least %v", i, gap, hookBackoffBase<<i)
+				}
+			}
+		})
+	}
+}
+
+func TestHookBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 2 * time.Second, 2500 * time.Millisecond},
+		{2, 4 * time.Second, 5 * time.Second},
+		{5, 32 * time.Second, 40 * time.Second},
+		{9, hookMaxBackoff, hookMaxBackoff * 5 / 4},
+		{20, hookMaxBackoff, hookMaxBackoff * 5 / 4},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if d := hookBackoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Errorf("hookBackoff(%d) = %v, want %v..%v", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestHookWants(t *testing.T) {
+	tests := []struct {
+		name        string
+		hook        webhook
+		event, room string
+		want        bool
+	}{
+		{"everything", webhook{}, hookDiceRolled, "A", true},
+		{"other room", webhook{Room: "A"}, hookDiceRolled, "B", false},
+		{"its room", webhook{Room: "A"}, hookDiceRolled, "A", true},
+		{"filtered out", webhook{Events: []string{hookGameEnded}}, hookDiceRolled, "A", false},
+		{"filtered in", webhook{Events: []string{hookGameEnded, hookDiceRolled}}, hookDiceRolled, "A", true},
+		{"ping always", webhook{Events: []string{hookGameEnded}}, hookPing, "", true},
+	}
+	for _, tt := range tests {
+		if got := tt.hook.wants(tt.event, tt.room); got != tt.want {
+			t.Errorf("%s: wants = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}